@@ -0,0 +1,246 @@
+package collect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// ErrSessionExpired 规则的ParseFunc返回该错误时，引擎重新登录后重试请求
+var ErrSessionExpired = errors.New("session expired")
+
+// Authenticator 爬取前的登录步骤，登录结果保存在会话中，由使用该会话的所有请求共享
+type Authenticator interface {
+	Login(ctx context.Context, task *Task, s *Session) error
+}
+
+// AuthFunc 函数形式的Authenticator
+type AuthFunc func(ctx context.Context, task *Task, s *Session) error
+
+func (f AuthFunc) Login(ctx context.Context, task *Task, s *Session) error {
+	return f(ctx, task, s)
+}
+
+// authState 会话的登录状态
+type authState struct {
+	// login 保证同一会话同时只有一个登录过程
+	login    sync.Mutex
+	mu       sync.Mutex
+	loggedIn bool
+	// gen 每次登录加1，用于忽略旧登录产生的过期通知
+	gen         int
+	token       string
+	tokenExpiry time.Time
+}
+
+// SetToken 设置请求携带的Bearer令牌，expiry为零值时不过期
+func (s *Session) SetToken(token string, expiry time.Time) {
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+	s.auth.token = token
+	s.auth.tokenExpiry = expiry
+}
+
+// Token 返回当前的Bearer令牌
+func (s *Session) Token() string {
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+	return s.auth.token
+}
+
+// EnsureLogin 会话未登录或令牌已过期时登录，多个请求同时调用时只登录一次
+// 返回当前登录的代数，会话过期时传给Expire
+func (s *Session) EnsureLogin(ctx context.Context, task *Task, auth Authenticator) (int, error) {
+	s.auth.login.Lock()
+	defer s.auth.login.Unlock()
+	s.auth.mu.Lock()
+	expired := !s.auth.tokenExpiry.IsZero() && time.Now().After(s.auth.tokenExpiry)
+	valid, gen := s.auth.loggedIn && !expired, s.auth.gen
+	s.auth.mu.Unlock()
+	if valid {
+		return gen, nil
+	}
+	// 重新登录前清除失效的cookie，首次登录保留从会话文件恢复的cookie
+	if gen > 0 {
+		s.Reset()
+	}
+	s.SetToken("", time.Time{})
+	if err := auth.Login(ctx, task, s); err != nil {
+		return gen, fmt.Errorf("login failed: %w", err)
+	}
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+	s.auth.loggedIn = true
+	s.auth.gen++
+
+	return s.auth.gen, nil
+}
+
+// Expire 标记第gen次登录已失效，下次请求前重新登录
+func (s *Session) Expire(gen int) {
+	s.auth.mu.Lock()
+	defer s.auth.mu.Unlock()
+	if s.auth.gen == gen {
+		s.auth.loggedIn = false
+	}
+}
+
+// FormLogin 提交登录表单，用户名和密码取自会话的账号
+type FormLogin struct {
+	// PageURL 登录页地址，设置后先获取登录页并提取CSRF令牌
+	PageURL string
+	// LoginURL 表单提交地址
+	LoginURL string
+	// UsernameField PasswordField 用户名与密码的表单字段名
+	UsernameField string
+	PasswordField string
+	// CSRFField CSRF令牌的表单字段名，从登录页同名的input或meta标签中提取
+	CSRFField string
+	// Fields 其他表单字段
+	Fields url.Values
+	// Check 校验登录结果，为空时只检查状态码
+	Check func(*Response) error
+}
+
+func (f *FormLogin) Login(ctx context.Context, task *Task, s *Session) error {
+	form := url.Values{}
+	for k, v := range f.Fields {
+		form[k] = append([]string(nil), v...)
+	}
+	if f.UsernameField != "" {
+		form.Set(f.UsernameField, s.Account.Username)
+	}
+	if f.PasswordField != "" {
+		form.Set(f.PasswordField, s.Account.Password)
+	}
+	if f.PageURL != "" {
+		page, err := s.fetch(ctx, task, &Request{Url: f.PageURL, Method: http.MethodGet})
+		if err != nil {
+			return err
+		}
+		if f.CSRFField != "" {
+			token := extractCSRF(page.Body, f.CSRFField)
+			if token == "" {
+				return fmt.Errorf("csrf field %s not found in %s", f.CSRFField, f.PageURL)
+			}
+			form.Set(f.CSRFField, token)
+		}
+	}
+	req := (&Request{Url: f.LoginURL}).SetForm(form)
+	if f.PageURL != "" {
+		req.SetHeader("Referer", f.PageURL)
+	}
+	resp, err := s.fetch(ctx, task, req)
+	if err != nil {
+		return err
+	}
+	if f.Check != nil {
+		return f.Check(resp)
+	}
+
+	return nil
+}
+
+// TokenLogin 用账号换取Bearer令牌，之后的请求携带Authorization请求头
+// 响应中包含expires_in时，令牌到期前会重新登录
+type TokenLogin struct {
+	TokenURL string
+	// Fields 请求体的其他字段，请求体以JSON格式发送
+	Fields map[string]any
+	// UsernameField PasswordField 默认为username与password
+	UsernameField string
+	PasswordField string
+	// TokenField 响应中令牌的字段名，默认为access_token
+	TokenField string
+}
+
+func (t *TokenLogin) Login(ctx context.Context, task *Task, s *Session) error {
+	body := map[string]any{}
+	for k, v := range t.Fields {
+		body[k] = v
+	}
+	body[defaultString(t.UsernameField, "username")] = s.Account.Username
+	body[defaultString(t.PasswordField, "password")] = s.Account.Password
+	req := &Request{Url: t.TokenURL}
+	if err := req.SetJSON(body); err != nil {
+		return err
+	}
+	resp, err := s.fetch(ctx, task, req)
+	if err != nil {
+		return err
+	}
+	var result map[string]any
+	if err := json.Unmarshal(resp.Body, &result); err != nil {
+		return fmt.Errorf("decode token response: %w", err)
+	}
+	token, _ := result[defaultString(t.TokenField, "access_token")].(string)
+	if token == "" {
+		return errors.New("token not found in response")
+	}
+	var expiry time.Time
+	if expiresIn, ok := result["expires_in"].(float64); ok && expiresIn > 0 {
+		// 提前到期，避免请求途中令牌失效
+		expiry = time.Now().Add(time.Duration(expiresIn*0.9) * time.Second)
+	}
+	s.SetToken(token, expiry)
+
+	return nil
+}
+
+// fetch 使用任务的Fetcher和当前会话发送登录相关的请求，不受任务限流影响
+func (s *Session) fetch(ctx context.Context, task *Task, req *Request) (*Response, error) {
+	if task.Fetcher == nil {
+		return nil, errors.New("task fetcher is nil")
+	}
+	req.Task = task
+	req.session = s
+	// 登录请求与使用该会话的请求选择相同的代理
+	if task.Sessions != nil {
+		req.SessionKey = task.Sessions.Key(s)
+	}
+	return task.Fetcher.Get(ctx, req)
+}
+
+// extractCSRF 从页面中提取name为field的input的value，或meta标签的content
+func extractCSRF(body []byte, field string) string {
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := z.Token()
+			if tag.Data != "input" && tag.Data != "meta" {
+				continue
+			}
+			var name, value string
+			for _, attr := range tag.Attr {
+				switch attr.Key {
+				case "name":
+					name = attr.Val
+				case "value", "content":
+					value = attr.Val
+				}
+			}
+			if strings.EqualFold(name, field) {
+				return value
+			}
+		}
+	}
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package collect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			http.SetCookie(w, &http.Cookie{Name: "csrf", Value: "c1", Path: "/"})
+			w.Write([]byte(`<form><input type="hidden" name="_csrf" value="c1"></form>`))
+			return
+		}
+		c, err := r.Cookie("csrf")
+		if err != nil || r.FormValue("_csrf") != c.Value || r.FormValue("user") != "tom" || r.FormValue("pass") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("sid"); err != nil || c.Value != "s1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	task := NewTask(WithFetcher(BaseFetch{}))
+	task.Sessions = NewSessionPool(Account{Username: "tom", Password: "secret"})
+	auth := &FormLogin{
+		PageURL:       srv.URL + "/login",
+		LoginURL:      srv.URL + "/login",
+		UsernameField: "user",
+		PasswordField: "pass",
+		CSRFField:     "_csrf",
+	}
+	req := &Request{Task: task, Url: srv.URL + "/private"}
+	gen, err := req.Session().EnsureLogin(context.Background(), task, auth)
+	require.NoError(t, err)
+	assert.Equal(t, 1, gen)
+	_, err = BaseFetch{}.Get(context.Background(), req)
+	assert.NoError(t, err)
+
+	// 已登录时不再登录，过期后重新登录
+	gen, err = req.Session().EnsureLogin(context.Background(), task, auth)
+	require.NoError(t, err)
+	assert.Equal(t, 1, gen)
+	req.Session().Expire(gen)
+	gen, err = req.Session().EnsureLogin(context.Background(), task, auth)
+	require.NoError(t, err)
+	assert.Equal(t, 2, gen)
+
+	task.Sessions = NewSessionPool(Account{Username: "tom", Password: "wrong"})
+	_, err = (&Request{Task: task}).Session().EnsureLogin(context.Background(), task, auth)
+	assert.Error(t, err)
+}
+
+func TestTokenLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["username"] != "tom" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"access_token":"t1","expires_in":3600}`))
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	task := NewTask(WithFetcher(BaseFetch{}))
+	task.Sessions = NewSessionPool(Account{Username: "tom"})
+	req := &Request{Task: task, Url: srv.URL + "/api"}
+	_, err := req.Session().EnsureLogin(context.Background(), task, &TokenLogin{TokenURL: srv.URL + "/token"})
+	require.NoError(t, err)
+	assert.Equal(t, "t1", req.Session().Token())
+	_, err = BaseFetch{}.Get(context.Background(), req)
+	assert.NoError(t, err)
+}
+
+// proxyKeyFetcher 记录每个请求选择代理时使用的键
+type proxyKeyFetcher struct {
+	keys []string
+}
+
+func (f *proxyKeyFetcher) Get(ctx context.Context, req *Request) (*Response, error) {
+	f.keys = append(f.keys, req.ProxyKey())
+	return &Response{StatusCode: http.StatusOK, Body: []byte(`{"access_token":"t1"}`)}, nil
+}
+
+func TestLoginProxyKey(t *testing.T) {
+	fetcher := &proxyKeyFetcher{}
+	task := NewTask(WithName("login"), WithFetcher(fetcher))
+	task.Sessions = NewSessionPool(Account{Username: "tom"}, Account{Username: "jerry"})
+	req := &Request{Task: task, Url: "http://example.com/api"}
+	session := req.Session()
+	_, err := session.EnsureLogin(context.Background(), task, &TokenLogin{TokenURL: "http://example.com/token"})
+	require.NoError(t, err)
+
+	// 登录请求与使用该会话的请求使用相同的代理
+	require.Len(t, fetcher.keys, 1)
+	assert.Equal(t, req.ProxyKey(), fetcher.keys[0])
+}
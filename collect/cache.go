@@ -0,0 +1,156 @@
+package collect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrCacheMiss 离线模式下请求没有缓存
+var ErrCacheMiss = errors.New("cache miss")
+
+// CacheFetcher 为Fetcher增加磁盘上的HTTP缓存
+// 缓存过的GET请求再次获取时携带If-None-Match/If-Modified-Since，服务端返回304时使用缓存的响应
+type CacheFetcher struct {
+	Fetcher Fetcher
+	// Dir 缓存目录
+	Dir string
+	// Offline 只从缓存读取，不发出请求，用于离线调试解析规则
+	Offline bool
+}
+
+// cacheEntry 缓存文件的内容
+type cacheEntry struct {
+	URL        string      `json:"url"`
+	StatusCode int         `json:"status_code"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header"`
+	FinalURL   string      `json:"final_url"`
+	Redirects  []string    `json:"redirects,omitempty"`
+	Charset    string      `json:"charset"`
+	Body       []byte      `json:"body"`
+	Time       time.Time   `json:"time"`
+}
+
+func (c *CacheFetcher) Get(ctx context.Context, req *Request) (*Response, error) {
+	entry, err := c.load(req)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if c.Offline {
+		if entry == nil {
+			return nil, ErrCacheMiss
+		}
+		return entry.response(), nil
+	}
+	if !cacheable(req) {
+		return c.Fetcher.Get(ctx, req)
+	}
+
+	conditional := req
+	if entry != nil {
+		etag, modified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified")
+		if etag != "" || modified != "" {
+			// 先确定会话，保证条件请求与原请求使用同一会话
+			req.Session()
+			clone := *req
+			clone.Header = req.Header.Clone()
+			if etag != "" {
+				clone.SetHeader("If-None-Match", etag)
+			}
+			if modified != "" {
+				clone.SetHeader("If-Modified-Since", modified)
+			}
+			conditional = &clone
+		}
+	}
+	resp, err := c.Fetcher.Get(ctx, conditional)
+	if resp != nil && resp.StatusCode == http.StatusNotModified && entry != nil {
+		cached := entry.response()
+		cached.Elapsed = resp.Elapsed
+		return cached, nil
+	}
+	if err == nil && resp.StatusCode == http.StatusOK {
+		if err := c.save(req, resp); err != nil {
+			return resp, err
+		}
+	}
+
+	return resp, err
+}
+
+// cacheable 只缓存GET请求
+func cacheable(req *Request) bool {
+	return req.Method == "" || req.Method == http.MethodGet
+}
+
+// ReportBan 转发给内部的Fetcher
+func (c *CacheFetcher) ReportBan(req *Request, resp *Response) {
+	if r, ok := c.Fetcher.(BanReporter); ok {
+		r.ReportBan(req, resp)
+	}
+}
+
+func (c *CacheFetcher) path(req *Request) string {
+	unique := req.Unique()
+	return filepath.Join(c.Dir, unique[:2], unique+".json")
+}
+
+func (c *CacheFetcher) load(req *Request) (*cacheEntry, error) {
+	b, err := os.ReadFile(c.path(req))
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *CacheFetcher) save(req *Request, resp *Response) error {
+	entry := cacheEntry{
+		URL:        req.Url,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Redirects:  resp.Redirects,
+		Charset:    resp.Charset,
+		Body:       resp.Body,
+		Time:       time.Now(),
+	}
+	if resp.URL != nil {
+		entry.FinalURL = resp.URL.String()
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := c.path(req)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (e *cacheEntry) response() *Response {
+	resp := &Response{
+		StatusCode: e.StatusCode,
+		Status:     e.Status,
+		Header:     e.Header,
+		Redirects:  e.Redirects,
+		Charset:    e.Charset,
+		Body:       e.Body,
+	}
+	resp.URL, _ = url.Parse(e.FinalURL)
+	return resp
+}
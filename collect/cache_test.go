@@ -0,0 +1,47 @@
+package collect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheFetcher(t *testing.T) {
+	var hits, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	fetcher := &CacheFetcher{Fetcher: BaseFetch{}, Dir: dir}
+	req := &Request{Task: NewTask(), Url: srv.URL + "/a"}
+	for i := 0; i < 2; i++ {
+		resp, err := fetcher.Get(context.Background(), req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "page /a", string(resp.Body))
+		assert.Equal(t, srv.URL+"/a", resp.URL.String())
+	}
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 1, notModified)
+
+	offline := &CacheFetcher{Dir: dir, Offline: true}
+	resp, err := offline.Get(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "page /a", string(resp.Body))
+	_, err = offline.Get(context.Background(), &Request{Task: NewTask(), Url: srv.URL + "/b"})
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	assert.Equal(t, 2, hits)
+}
@@ -0,0 +1,199 @@
+package collect
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrNotRecorded 回放时请求没有录制过
+var ErrNotRecorded = errors.New("request not recorded")
+
+// interaction 磁带中的一行，记录一次请求及其响应
+// 请求失败且没有响应时只记录错误信息
+type interaction struct {
+	Request  recordedRequest   `json:"request"`
+	Response *recordedResponse `json:"response,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int           `json:"status_code"`
+	Status     string        `json:"status"`
+	Header     http.Header   `json:"header,omitempty"`
+	URL        string        `json:"url"`
+	Redirects  []string      `json:"redirects,omitempty"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Charset    string        `json:"charset,omitempty"`
+	// Body 响应体已由fetcher转换为utf-8，以字符串保存便于查看和修改
+	Body string `json:"body"`
+}
+
+func interactionKey(method, u, body string) string {
+	if method == "" {
+		method = http.MethodGet
+	}
+	return method + " " + u + "\n" + body
+}
+
+// RecordingFetcher 将经过Fetcher的每个请求与响应按JSONL格式追加到磁带文件
+type RecordingFetcher struct {
+	Fetcher Fetcher
+	mu      sync.Mutex
+	file    *os.File
+	enc     *json.Encoder
+}
+
+func NewRecordingFetcher(fetcher Fetcher, path string) (*RecordingFetcher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	return &RecordingFetcher{
+		Fetcher: fetcher,
+		file:    f,
+		enc:     enc,
+	}, nil
+}
+
+func (r *RecordingFetcher) Get(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := r.Fetcher.Get(ctx, req)
+	// 取消的请求不录制，避免回放时出现停止爬虫导致的错误
+	if errors.Is(err, context.Canceled) {
+		return resp, err
+	}
+	in := interaction{
+		Request: recordedRequest{
+			Method: req.Method,
+			Url:    req.Url,
+			Header: req.Header,
+			Body:   string(req.Body),
+		},
+	}
+	if resp != nil {
+		in.Response = &recordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header,
+			Redirects:  resp.Redirects,
+			Elapsed:    resp.Elapsed,
+			Charset:    resp.Charset,
+			Body:       string(resp.Body),
+		}
+		if resp.URL != nil {
+			in.Response.URL = resp.URL.String()
+		}
+	} else if err != nil {
+		in.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if werr := r.enc.Encode(in); werr != nil {
+		return resp, fmt.Errorf("record interaction: %w", werr)
+	}
+
+	return resp, err
+}
+
+// ReportBan 转发给内部的Fetcher
+func (r *RecordingFetcher) ReportBan(req *Request, resp *Response) {
+	if br, ok := r.Fetcher.(BanReporter); ok {
+		br.ReportBan(req, resp)
+	}
+}
+
+func (r *RecordingFetcher) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// ReplayFetcher 从磁带回放响应，不发出网络请求
+// 同一请求录制了多次时按录制顺序返回，用完后重复返回最后一次
+type ReplayFetcher struct {
+	mu           sync.Mutex
+	interactions map[string][]interaction
+}
+
+func NewReplayFetcher(path string) (*ReplayFetcher, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := &ReplayFetcher{interactions: map[string][]interaction{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var in interaction
+		if err := json.Unmarshal(scanner.Bytes(), &in); err != nil {
+			return nil, fmt.Errorf("cassette %s line %d: %w", path, line, err)
+		}
+		key := interactionKey(in.Request.Method, in.Request.Url, in.Request.Body)
+		r.interactions[key] = append(r.interactions[key], in)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *ReplayFetcher) Get(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	key := interactionKey(req.Method, req.Url, string(req.Body))
+	r.mu.Lock()
+	list := r.interactions[key]
+	if len(list) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, req.Method, req.Url)
+	}
+	in := list[0]
+	if len(list) > 1 {
+		r.interactions[key] = list[1:]
+	}
+	r.mu.Unlock()
+
+	if in.Response == nil {
+		return nil, errors.New(in.Error)
+	}
+	rec := in.Response
+	resp := &Response{
+		StatusCode: rec.StatusCode,
+		Status:     rec.Status,
+		Header:     rec.Header,
+		Redirects:  rec.Redirects,
+		Elapsed:    rec.Elapsed,
+		Charset:    rec.Charset,
+		Body:       []byte(rec.Body),
+	}
+	resp.URL, _ = url.Parse(rec.URL)
+	if resp.StatusCode != http.StatusOK {
+		return resp, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,57 @@
+package collect
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<p>" + r.Method + " " + r.URL.Path + "</p>"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	rec, err := NewRecordingFetcher(BaseFetch{}, path)
+	require.NoError(t, err)
+	task := NewTask()
+	reqs := []*Request{
+		{Task: task, Url: srv.URL + "/a"},
+		(&Request{Task: task, Url: srv.URL + "/search"}).SetForm(map[string][]string{"q": {"go"}}),
+		{Task: task, Url: srv.URL + "/missing"},
+	}
+	var recorded []*Response
+	for _, req := range reqs {
+		resp, _ := rec.Get(context.Background(), req)
+		recorded = append(recorded, resp)
+	}
+	require.NoError(t, rec.Close())
+	srv.Close()
+
+	replay, err := NewReplayFetcher(path)
+	require.NoError(t, err)
+	for i, req := range reqs {
+		resp, err := replay.Get(context.Background(), req)
+		if i == 2 {
+			var se *StatusError
+			require.ErrorAs(t, err, &se)
+			assert.Equal(t, http.StatusNotFound, se.StatusCode)
+		} else {
+			require.NoError(t, err)
+		}
+		assert.Equal(t, recorded[i].Body, resp.Body)
+		assert.Equal(t, recorded[i].URL.String(), resp.URL.String())
+	}
+	_, err = replay.Get(context.Background(), &Request{Task: task, Url: srv.URL + "/b"})
+	assert.ErrorIs(t, err, ErrNotRecorded)
+}
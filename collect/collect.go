@@ -2,9 +2,11 @@ package collect
 
 import (
 	"bufio"
-	"fmt"
-	"io"
+	"context"
+	"errors"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/awaketai/crawler/extensions"
@@ -13,7 +15,6 @@ import (
 	"golang.org/x/net/html/charset"
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
 )
 
 type FetchType string
@@ -23,79 +24,191 @@ const (
 	BrowserFetchType FetchType = "browser"
 )
 
+// Fetcher 获取请求的响应，状态码不是200时同时返回响应与*StatusError
 type Fetcher interface {
-	Get(*Request) ([]byte, error)
+	Get(context.Context, *Request) (*Response, error)
+}
+
+// BanReporter 请求被目标站点封禁时由引擎调用，使用代理的Fetcher借此更换代理
+type BanReporter interface {
+	ReportBan(*Request, *Response)
 }
 
 type BaseFetch struct {
 }
 
-func (BaseFetch) Get(req *Request) ([]byte, error) {
-	resp, err := http.Get(req.Url)
+func (BaseFetch) Get(ctx context.Context, req *Request) (*Response, error) {
+	request, err := req.HTTPRequest(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("error http status:%v %v", resp.StatusCode, resp.Status)
-		return nil, fmt.Errorf("error http status:%v", resp.Status)
+	client := http.DefaultClient
+	if session := req.Session(); session != nil {
+		client = &http.Client{Jar: session}
+	}
+	start := time.Now()
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
 	}
-	bodyReader := bufio.NewReader(resp.Body)
-	e := DeterminEncoding(bodyReader)
-	utf8Reader := transform.NewReader(bodyReader, e.NewDecoder())
 
-	return io.ReadAll(utf8Reader)
+	return readResponse(resp, start)
 }
 
+// BrowserFetch 模拟浏览器获取页面，所有请求共用一个Transport
+// 可以直接构造，也可以通过NewBrowserFetch设置Transport，第一次请求后修改字段不再生效
 type BrowserFetch struct {
 	Timeout time.Duration
 	Proxy   proxy.ProxyFunc
-	Logger  *zap.Logger
+	// Pool 代理池，设置后优先于Proxy，请求结果会反馈给代理池
+	Pool   *proxy.Pool
+	Logger *zap.Logger
+
+	once      sync.Once
+	transport *http.Transport
+	client    *http.Client
+	// clients 每个会话使用单独的Client以携带各自的cookie，Transport共用
+	clients sync.Map
 }
 
-func (b BrowserFetch) Get(req *Request) ([]byte, error) {
-	client := &http.Client{
-		Timeout: b.Timeout,
+func (b *BrowserFetch) init() {
+	b.once.Do(func() {
+		if b.transport == nil {
+			b.transport = NewTransport(TransportConfig{})
+		}
+		b.transport.Proxy = b.proxyFunc
+		b.client = &http.Client{
+			Transport: b.transport,
+			Timeout:   b.Timeout,
+		}
+	})
+}
+
+// proxyFunc 优先使用ctx中携带的从代理池选择的代理
+func (b *BrowserFetch) proxyFunc(req *http.Request) (*url.URL, error) {
+	if u := proxy.FromContext(req.Context()); u != nil {
+		return u, nil
+	}
+	if b.Pool != nil {
+		return b.Pool.ProxyFunc(req)
 	}
-	// 设置代理服务
 	if b.Proxy != nil {
-		trasnport := http.DefaultTransport.(*http.Transport)
-		trasnport.Proxy = b.Proxy
-		client.Transport = trasnport
+		return b.Proxy(req)
 	}
+	return nil, nil
+}
+
+func (b *BrowserFetch) clientFor(session *Session) *http.Client {
+	if session == nil {
+		return b.client
+	}
+	if c, ok := b.clients.Load(session); ok {
+		return c.(*http.Client)
+	}
+	c, _ := b.clients.LoadOrStore(session, &http.Client{
+		Transport: b.transport,
+		Timeout:   b.Timeout,
+		Jar:       session,
+	})
+	return c.(*http.Client)
+}
 
-	request, err := http.NewRequest("GET", req.Url, nil)
+// CloseIdleConnections 关闭Transport中的空闲连接
+func (b *BrowserFetch) CloseIdleConnections() {
+	b.init()
+	b.transport.CloseIdleConnections()
+}
+
+func (b *BrowserFetch) Get(ctx context.Context, req *Request) (*Response, error) {
+	b.init()
+	// 从代理池选择的代理通过ctx传给Transport，便于请求结束后反馈结果
+	var proxyURL *url.URL
+	pool := b.poolFor(req)
+	if pool != nil {
+		u, err := pool.PickFor(req.ProxyKey())
+		if err != nil {
+			return nil, err
+		}
+		proxyURL = u
+		ctx = proxy.WithProxyURL(ctx, u)
+	}
+
+	request, err := req.HTTPRequest(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if len(req.Task.Cookie) > 0 {
+	// 使用会话时由会话携带cookie
+	session := req.Session()
+	client := b.clientFor(session)
+	if session == nil && len(req.Task.Cookie) > 0 && request.Header.Get("Cookie") == "" {
 		request.Header.Set("Cookie", req.Task.Cookie)
 	}
-	userAgent := extensions.GenerateRandomUA()
-	request.Header.Set("User-Agent", userAgent)
+	if request.Header.Get("User-Agent") == "" {
+		userAgent := extensions.GenerateRandomUA()
+		request.Header.Set("User-Agent", userAgent)
+	}
+	start := time.Now()
 	resp, err := client.Do(request)
+	if proxyURL != nil && ctx.Err() == nil {
+		pool.Report(proxyURL, proxyError(resp, err), time.Since(start))
+	}
 
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("error http status:%v %v", resp.StatusCode, resp.Status)
-		return nil, fmt.Errorf("error http status:%v", resp.Status)
+	r, err := readResponse(resp, start)
+	if r != nil {
+		r.Proxy = proxyURL
 	}
-	bodyReader := bufio.NewReader(resp.Body)
-	e := DeterminEncoding(bodyReader)
-	utf8Reader := transform.NewReader(bodyReader, e.NewDecoder())
 
-	return io.ReadAll(utf8Reader)
+	return r, err
 }
 
-func DeterminEncoding(r *bufio.Reader) encoding.Encoding {
-	bytes, err := r.Peek(1024)
+// poolFor 请求使用的代理池，任务设置的代理池优先
+func (b *BrowserFetch) poolFor(req *Request) *proxy.Pool {
+	if req.Task != nil && req.Task.Proxy != nil {
+		return req.Task.Proxy
+	}
+	return b.Pool
+}
+
+// errBanned 代理被目标站点封禁时反馈给代理池的错误
+var errBanned = errors.New("banned by target site")
+
+// ReportBan 被封禁的代理记为一次失败，并解除请求的代理绑定，之后重新选择代理
+func (b *BrowserFetch) ReportBan(req *Request, resp *Response) {
+	pool := b.poolFor(req)
+	if pool == nil {
+		return
+	}
+	if resp != nil && resp.Proxy != nil {
+		pool.Report(resp.Proxy, errBanned, 0)
+	}
+	pool.Unbind(req.ProxyKey())
+}
+
+// proxyError 请求失败或代理认证失败时视为代理不可用
+func proxyError(resp *http.Response, err error) error {
 	if err != nil {
-		fmt.Println("determin encoding err:", err)
-		return unicode.UTF8
+		return err
+	}
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
-	e, _, _ := charset.DetermineEncoding(bytes, "")
+	return nil
+}
+
+func DeterminEncoding(r *bufio.Reader) encoding.Encoding {
+	e, _ := determineEncoding(r, "")
 	return e
 }
+
+// determineEncoding 根据响应头与页面内容检测编码，返回编码及其名称
+func determineEncoding(r *bufio.Reader, contentType string) (encoding.Encoding, string) {
+	bytes, err := r.Peek(1024)
+	if err != nil && len(bytes) == 0 {
+		return unicode.UTF8, "utf-8"
+	}
+	e, name, _ := charset.DetermineEncoding(bytes, contentType)
+	return e, name
+}
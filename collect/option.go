@@ -3,6 +3,7 @@ package collect
 import (
 	"github.com/awaketai/crawler/collector"
 	"github.com/awaketai/crawler/limiter"
+	"github.com/awaketai/crawler/proxy"
 	"go.uber.org/zap"
 )
 
@@ -10,7 +11,7 @@ type Options struct {
 	Name     string              `json:"name"`      // 任务名称，保证唯一
 	Url      string              `json:"url"`       // 任务url
 	Cookie   string              `json:"cookie"`    // 任务cookie
-	WaitTime int64               `json:"wait_time"` // 任务等待时间
+	WaitTime int64               `json:"wait_time"` // 同一站点两次请求之间随机等待的上限，单位秒，为0时不等待
 	Reload   bool                `json:"reload"`    // 任务是否可以重复爬取
 	MaxDepth int                 `json:"max_depth"` // 任务最大深度
 	Fetcher  Fetcher           // 任务的Fetcher
@@ -18,20 +19,60 @@ type Options struct {
 	Logger   *zap.Logger         // 任务的Logger
 	Limit    limiter.RateLimiter // 任务的RateLimiter
 	LimitCfg []LimitConfig `json:"Limits"`
+	// RuleLimits 规则的RateLimiter，设置后该规则的请求只受规则的限速，不再经过任务的Limit
+	RuleLimits   map[string]limiter.RateLimiter `json:"-"`
+	RuleLimitCfg map[string][]LimitConfig       `json:"rule_limits"`
 	FetchType FetchType
+	Retry    RetryPolicy `json:"retry"` // 任务的失败重试策略
+	// Cron 定时重新爬取的计划，格式见cron.Parse，为空时只爬取一次
+	Cron string `json:"cron"`
+	// ChangedOnly 再次获取的页面内容与上次相同时不再解析
+	ChangedOnly bool `json:"changed_only"`
+	// Accounts 任务使用的账号，配置后每个账号使用独立的会话，请求之间轮流使用
+	Accounts []Account `json:"accounts"`
+	// SessionFile 会话cookie的持久化文件，启动时恢复，停止时保存
+	SessionFile string `json:"session_file"`
+	// IgnoreRobots 不检查robots.txt
+	IgnoreRobots bool `json:"ignore_robots"`
+	// Validators 响应校验，结果为Ban或SoftFailure时按重试策略重试
+	Validators   []Validator       `json:"-"`
+	ValidatorCfg []ValidatorConfig `json:"validators"`
+	// BanCoolDown 被封禁后站点暂停请求的时长，单位秒
+	BanCoolDown int64 `json:"ban_cool_down"`
+	// OnBan 请求被封禁时调用，可用于切换账号，使用代理池时Fetcher会更换被封禁的代理
+	OnBan func(*Request, *Response) `json:"-"`
+	// Sessions 任务的会话池，为空且配置了Accounts或SessionFile时由引擎创建
+	Sessions *SessionPool `json:"-"`
+	// ProxyGroup 任务使用的代理组，为空时使用fetcher的代理
+	ProxyGroup string      `json:"proxy_group"`
+	Proxy      *proxy.Pool `json:"-"`
+	// StickyProxy 未使用会话的请求如何保持代理，可选 task host，为空时每次请求重新选择
+	StickyProxy string `json:"sticky_proxy"`
 }
 
+const (
+	// StickyTask 同一任务的请求使用同一代理
+	StickyTask = "task"
+	// StickyHost 同一站点的请求使用同一代理
+	StickyHost = "host"
+)
+
 type LimitConfig struct {
 	EventCount int
 	EventDur   int // seconds
-	Bucket     int
+	Bucket     int // 令牌桶容量，允许的突发请求数，小于1时为1
+	// Adaptive 根据站点反馈自适应调整速率，速率在配置速率的1/10到1倍之间变化
+	Adaptive bool
+	// Distributed 所有worker通过etcd共享该限速
+	Distributed bool
 }
 
 var defaultOptions = Options{
-	WaitTime: 5,
-	Reload:   false,
-	MaxDepth: 5,
-	Logger:   zap.NewNop(),
+	WaitTime:    5,
+	Reload:      false,
+	MaxDepth:    5,
+	Logger:      zap.NewNop(),
+	BanCoolDown: 60,
 }
 
 type Option func(options *Options)
@@ -95,3 +136,86 @@ func WithLimit(limit limiter.RateLimiter) Option {
 		options.Limit = limit
 	}
 }
+
+// WithRuleLimit 为名称为rule的规则单独限速，代替任务的限速
+func WithRuleLimit(rule string, limit limiter.RateLimiter) Option {
+	return func(options *Options) {
+		if options.RuleLimits == nil {
+			options.RuleLimits = map[string]limiter.RateLimiter{}
+		}
+		options.RuleLimits[rule] = limit
+	}
+}
+
+// WithProxy 任务使用单独的代理池
+func WithProxy(pool *proxy.Pool) Option {
+	return func(options *Options) {
+		options.Proxy = pool
+	}
+}
+
+func WithStickyProxy(sticky string) Option {
+	return func(options *Options) {
+		options.StickyProxy = sticky
+	}
+}
+
+func WithRetry(retry RetryPolicy) Option {
+	return func(options *Options) {
+		options.Retry = retry
+	}
+}
+
+func WithCron(spec string) Option {
+	return func(options *Options) {
+		options.Cron = spec
+	}
+}
+
+func WithChangedOnly(changedOnly bool) Option {
+	return func(options *Options) {
+		options.ChangedOnly = changedOnly
+	}
+}
+
+func WithAccounts(accounts ...Account) Option {
+	return func(options *Options) {
+		options.Accounts = accounts
+	}
+}
+
+func WithSessionFile(path string) Option {
+	return func(options *Options) {
+		options.SessionFile = path
+	}
+}
+
+func WithSessions(pool *SessionPool) Option {
+	return func(options *Options) {
+		options.Sessions = pool
+	}
+}
+
+func WithIgnoreRobots(ignore bool) Option {
+	return func(options *Options) {
+		options.IgnoreRobots = ignore
+	}
+}
+
+func WithValidators(validators ...Validator) Option {
+	return func(options *Options) {
+		options.Validators = validators
+	}
+}
+
+func WithBanCoolDown(seconds int64) Option {
+	return func(options *Options) {
+		options.BanCoolDown = seconds
+	}
+}
+
+func WithOnBan(onBan func(*Request, *Response)) Option {
+	return func(options *Options) {
+		options.OnBan = onBan
+	}
+}
@@ -1,29 +1,56 @@
 package collect
 
 import (
+	"bytes"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
-	"math/rand"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/awaketai/crawler/limiter"
 )
 
 // Request 单个请求
 type Request struct {
 	unique string
-	Task   *Task
-	Url    string
+	// session 请求使用的会话，重试时保持不变
+	session *Session
+	Task    *Task
+	Url     string
 	// Depth 当前任务的深度
-	Depth     int
-	Method    string
+	Depth int
+	// Method 为空时使用GET
+	Method string
+	// Header 请求头，会覆盖fetcher默认设置的同名请求头
+	Header http.Header
+	Body   []byte
+	// Timeout 单个请求的超时时间，为0时不单独限制
+	Timeout   time.Duration
 	Priority  int
 	ParseFunc func([]byte, *Request) ParseResult
 	RuleName  string
 	TmpData   *Tmp
-	// TestBody 测试用
+	// TestBody 测试用，设置Test时不发出请求，直接使用TestBody
+	// Deprecated: 使用ReplayFetcher回放录制的响应
 	TestBody []byte
 	Test     bool
+	// Retries 已失败的次数
+	Retries int
+	// NotBefore 请求最早的执行时间，为零值时立即执行，只影响调度时间，不影响重复访问检查
+	NotBefore time.Time
+	// Poll 轮询请求，不做重复访问检查，可用于定时重复获取同一页面
+	Poll bool
+	// SessionKey 请求使用的会话，键相同的请求使用同一会话与同一代理
+	// 为空时由会话池分配，分配后记录在此，保证重试与恢复后的请求使用同一会话
+	SessionKey string
+	// QueueID 请求在调度队列持久化存储中的编号，为0表示未保存，由QueueStore维护
+	QueueID uint64
 }
 
 type ParseResult struct {
@@ -39,18 +66,162 @@ func (r *Request) Check() error {
 	return nil
 }
 
+// Unique 请求的唯一标识，请求体不同的POST请求视为不同请求
 func (r *Request) Unique() string {
-	block := md5.Sum([]byte(r.Url + r.Method))
+	block := md5.Sum([]byte(r.Url + r.Method + string(r.Body)))
 	return hex.EncodeToString(block[:])
 }
 
-func (r *Request) Fetch(ctx context.Context) ([]byte, error) {
-	if err := r.Task.Limit.Wait(ctx); err != nil {
+// SetHeader 设置请求头
+func (r *Request) SetHeader(key, value string) *Request {
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	r.Header.Set(key, value)
+	return r
+}
+
+// SetQuery 设置url的查询参数，同名参数会被覆盖
+func (r *Request) SetQuery(key, value string) *Request {
+	return r.editQuery(func(q url.Values) { q.Set(key, value) })
+}
+
+// AddQuery 追加url的查询参数
+func (r *Request) AddQuery(key, value string) *Request {
+	return r.editQuery(func(q url.Values) { q.Add(key, value) })
+}
+
+func (r *Request) editQuery(edit func(url.Values)) *Request {
+	u, err := url.Parse(r.Url)
+	if err != nil {
+		return r
+	}
+	q := u.Query()
+	edit(q)
+	u.RawQuery = q.Encode()
+	r.Url = u.String()
+	return r
+}
+
+// SetForm 以表单格式设置请求体，未指定方法时使用POST
+func (r *Request) SetForm(values url.Values) *Request {
+	r.Body = []byte(values.Encode())
+	r.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	if r.Method == "" || r.Method == http.MethodGet {
+		r.Method = http.MethodPost
+	}
+	return r
+}
+
+// SetJSON 以JSON格式设置请求体，未指定方法时使用POST
+func (r *Request) SetJSON(v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	r.SetHeader("Content-Type", "application/json")
+	if r.Method == "" || r.Method == http.MethodGet {
+		r.Method = http.MethodPost
+	}
+	return nil
+}
+
+// Session 返回请求使用的会话，任务没有会话池时返回nil
+func (r *Request) Session() *Session {
+	if r.session == nil && r.Task != nil && r.Task.Sessions != nil {
+		if r.SessionKey != "" {
+			r.session = r.Task.Sessions.Lookup(r.SessionKey)
+		}
+		if r.session == nil {
+			r.session = r.Task.Sessions.Next()
+			r.SessionKey = r.Task.Sessions.Key(r.session)
+		}
+	}
+	return r.session
+}
+
+// ProxyKey 选择代理时使用的键，键相同的请求使用同一代理，为空时每次请求重新选择
+// 设置了SessionKey或使用会话时按会话，否则按任务的StickyProxy
+func (r *Request) ProxyKey() string {
+	if r.Task == nil {
+		return r.SessionKey
+	}
+	r.Session()
+	if r.SessionKey != "" {
+		return r.Task.Name + "/session/" + r.SessionKey
+	}
+	switch r.Task.StickyProxy {
+	case StickyTask:
+		return r.Task.Name
+	case StickyHost:
+		u, err := url.Parse(r.Url)
+		if err != nil {
+			return ""
+		}
+		return r.Task.Name + "/host/" + u.Hostname()
+	}
+	return ""
+}
+
+// HTTPRequest 根据请求的方法、请求头和请求体构造http请求
+func (r *Request) HTTPRequest(ctx context.Context) (*http.Request, error) {
+	method := strings.ToUpper(r.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if len(r.Body) > 0 {
+		body = bytes.NewReader(r.Body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, r.Url, body)
+	if err != nil {
 		return nil, err
 	}
-	// 随机休眠
-	sleepTime := rand.Int63n(r.Task.WaitTime * 1000)
-	time.Sleep(time.Duration(sleepTime) * time.Millisecond)
+	for key, values := range r.Header {
+		request.Header[key] = append([]string(nil), values...)
+	}
+	if session := r.Session(); session != nil && request.Header.Get("Authorization") == "" {
+		if token := session.Token(); token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	return request, nil
+}
+
+// Delay 设置请求在d之后执行
+func (r *Request) Delay(d time.Duration) *Request {
+	r.NotBefore = time.Now().Add(d)
+	return r
+}
+
+// PollAfter 设置请求为轮询请求，在d之后再次获取，即使页面已访问过
+func (r *Request) PollAfter(d time.Duration) *Request {
+	r.Poll = true
+	return r.Delay(d)
+}
+
+// Limiter 请求使用的限流器，规则单独设置了限流器时代替任务的限流器，都没有设置时返回nil
+func (r *Request) Limiter() limiter.RateLimiter {
+	if l := r.Task.RuleLimits[r.RuleName]; l != nil {
+		return l
+	}
+	return r.Task.Limit
+}
+
+// Fetch 等待限流后获取页面，任务WaitTime的随机等待由调度器在分配请求时完成
+func (r *Request) Fetch(ctx context.Context) (*Response, error) {
+	if l := r.Limiter(); l != nil {
+		if err := l.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
 
-	return r.Task.Fetcher.Get(r)
+	return r.Task.Fetcher.Get(ctx, r)
 }
@@ -0,0 +1,157 @@
+package collect
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/limiter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	taskLimit := limiter.NewLimiter(1, 1)
+	ruleLimit := limiter.NewLimiter(10, 1)
+	task := NewTask(WithRuleLimit("detail", ruleLimit))
+	task.Limit = taskLimit
+
+	// 规则的限流器代替任务的限流器
+	assert.Same(t, ruleLimit, (&Request{Task: task, RuleName: "detail"}).Limiter())
+	assert.Same(t, taskLimit, (&Request{Task: task, RuleName: "list"}).Limiter())
+	assert.Nil(t, (&Request{Task: NewTask(), RuleName: "list"}).Limiter())
+	assert.Equal(t, rate.Limit(10), (&Request{Task: task, RuleName: "detail"}).Limiter().Limit())
+}
+
+// recordServer 记录收到的请求
+func recordServer(t *testing.T) (*httptest.Server, <-chan *http.Request, <-chan []byte) {
+	reqs := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs <- r
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv, reqs, bodies
+}
+
+func TestHTTPRequest(t *testing.T) {
+	srv, reqs, bodies := recordServer(t)
+	tests := []struct {
+		name            string
+		req             *Request
+		wantMethod      string
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:       "get",
+			req:        &Request{Url: srv.URL},
+			wantMethod: http.MethodGet,
+		},
+		{
+			name:            "form",
+			req:             (&Request{Url: srv.URL}).SetForm(url.Values{"q": {"a b"}, "page": {"2"}}),
+			wantMethod:      http.MethodPost,
+			wantContentType: "application/x-www-form-urlencoded",
+			wantBody:        "page=2&q=a+b",
+		},
+		{
+			// 已指定的方法不被覆盖
+			name:            "form put",
+			req:             (&Request{Url: srv.URL, Method: "put"}).SetForm(url.Values{"q": {"a"}}),
+			wantMethod:      http.MethodPut,
+			wantContentType: "application/x-www-form-urlencoded",
+			wantBody:        "q=a",
+		},
+		{
+			name:            "header",
+			req:             (&Request{Url: srv.URL, Method: http.MethodPost, Body: []byte("raw")}).SetHeader("Content-Type", "text/plain"),
+			wantMethod:      http.MethodPost,
+			wantContentType: "text/plain",
+			wantBody:        "raw",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := tt.req.HTTPRequest(context.Background())
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(request)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			got := <-reqs
+			assert.Equal(t, tt.wantMethod, got.Method)
+			assert.Equal(t, tt.wantContentType, got.Header.Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, string(<-bodies))
+		})
+	}
+}
+
+func TestSetJSON(t *testing.T) {
+	srv, reqs, bodies := recordServer(t)
+	req := &Request{Url: srv.URL}
+	require.NoError(t, req.SetJSON(map[string]any{"id": 1, "name": "book"}))
+	req.SetHeader("X-Token", "abc")
+	request, err := req.HTTPRequest(context.Background())
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	got := <-reqs
+	assert.Equal(t, http.MethodPost, got.Method)
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	assert.Equal(t, "abc", got.Header.Get("X-Token"))
+	assert.JSONEq(t, `{"id":1,"name":"book"}`, string(<-bodies))
+
+	// 无法序列化时不修改请求
+	req = &Request{Url: srv.URL}
+	assert.Error(t, req.SetJSON(make(chan int)))
+	assert.Empty(t, req.Method)
+	assert.Nil(t, req.Body)
+}
+
+func TestQuery(t *testing.T) {
+	srv, reqs, _ := recordServer(t)
+	req := (&Request{Url: srv.URL + "/search?q=old&tag=a"}).
+		SetQuery("q", "go 语言").
+		AddQuery("tag", "b&c").
+		SetQuery("page", "2")
+	assert.Equal(t, srv.URL+"/search?page=2&q=go+%E8%AF%AD%E8%A8%80&tag=a&tag=b%26c", req.Url)
+
+	request, err := req.HTTPRequest(context.Background())
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	resp.Body.Close()
+	got := <-reqs
+	assert.Equal(t, "go 语言", got.URL.Query().Get("q"))
+	assert.Equal(t, []string{"a", "b&c"}, got.URL.Query()["tag"])
+
+	// 无法解析的url保持不变
+	bad := (&Request{Url: "://bad"}).SetQuery("q", "a")
+	assert.Equal(t, "://bad", bad.Url)
+}
+
+func TestRequestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	task := NewTask(WithFetcher(BaseFetch{}))
+
+	start := time.Now()
+	_, err := (&Request{Task: task, Url: srv.URL, Timeout: 50 * time.Millisecond}).Fetch(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,81 @@
+package collect
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/text/transform"
+)
+
+// Response 请求的响应
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// URL 重定向后的最终地址
+	URL *url.URL
+	// Redirects 依次经过的重定向地址，不包含最终地址
+	Redirects []string
+	// Elapsed 从发出请求到读取完响应体的耗时
+	Elapsed time.Duration
+	// Charset 检测到的页面编码，Body已转换为utf-8
+	Charset string
+	Body    []byte
+	// Proxy 获取响应使用的代理，没有使用代理池时为空
+	Proxy *url.URL
+}
+
+// ContentType 响应的媒体类型，不包含参数
+func (r *Response) ContentType() string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// ResolveURL 以最终地址为基准解析相对链接
+func (r *Response) ResolveURL(ref string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	if r.URL == nil {
+		return u.String(), nil
+	}
+	return r.URL.ResolveReference(u).String(), nil
+}
+
+// readResponse 读取响应体并转换为utf-8，状态码不是200时同时返回响应与StatusError
+func readResponse(resp *http.Response, start time.Time) (*Response, error) {
+	defer resp.Body.Close()
+	bodyReader := bufio.NewReader(resp.Body)
+	e, name := determineEncoding(bodyReader, resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(transform.NewReader(bodyReader, e.NewDecoder()))
+	if err != nil {
+		return nil, err
+	}
+	res := &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Elapsed:    time.Since(start),
+		Charset:    name,
+		Body:       body,
+	}
+	if resp.Request != nil {
+		res.URL = resp.Request.URL
+		for r := resp.Request; r.Response != nil && r.Response.Request != nil; r = r.Response.Request {
+			res.Redirects = append([]string{r.Response.Request.URL.String()}, res.Redirects...)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return res, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return res, nil
+}
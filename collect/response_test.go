@@ -0,0 +1,107 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func TestReadResponse(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String("<html><body>中文页面</body></html>")
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusFound)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/book/c?id=1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/book/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=gbk")
+		w.Write([]byte(gbk))
+	})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := BaseFetch{}.Get(context.Background(), &Request{Url: srv.URL + "/a"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// 重定向按经过的顺序记录，不包含最终地址
+	assert.Equal(t, []string{srv.URL + "/a", srv.URL + "/b"}, resp.Redirects)
+	assert.Equal(t, srv.URL+"/book/c?id=1", resp.URL.String())
+	assert.Equal(t, "gbk", resp.Charset)
+	assert.Equal(t, "text/html", resp.ContentType())
+	assert.Equal(t, "<html><body>中文页面</body></html>", string(resp.Body))
+	assert.Positive(t, resp.Elapsed)
+
+	// 状态码不是200时同时返回响应与StatusError
+	resp, err = BaseFetch{}.Get(context.Background(), &Request{Url: srv.URL + "/missing"})
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "not found\n", string(resp.Body))
+	assert.Empty(t, resp.Redirects)
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
+
+func TestResolveURL(t *testing.T) {
+	final, err := url.Parse("https://book.example.com/subject/1/?from=list")
+	require.NoError(t, err)
+	resp := &Response{URL: final}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"comments", "https://book.example.com/subject/1/comments"},
+		{"../2/", "https://book.example.com/subject/2/"},
+		{"/tag/go", "https://book.example.com/tag/go"},
+		{"?page=2", "https://book.example.com/subject/1/?page=2"},
+		{"//img.example.com/a.jpg", "https://img.example.com/a.jpg"},
+		{"http://other.com/x", "http://other.com/x"},
+	}
+	for _, tt := range tests {
+		got, err := resp.ResolveURL(tt.ref)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.ref)
+	}
+	_, err = resp.ResolveURL("%zz")
+	assert.Error(t, err)
+
+	// 没有最终地址时原样返回
+	got, err := (&Response{}).ResolveURL("a/b")
+	require.NoError(t, err)
+	assert.Equal(t, "a/b", got)
+}
+
+func TestCrawlerContextResolveURL(t *testing.T) {
+	final, err := url.Parse("https://m.example.com/book/1")
+	require.NoError(t, err)
+	req := &Request{Url: "https://example.com/book/1"}
+
+	// 重定向后以最终地址为基准
+	ctx := &CrawlerContext{Req: req, Resp: &Response{URL: final}}
+	got, err := ctx.ResolveURL("2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://m.example.com/book/2", got)
+
+	// 没有响应时以请求地址为基准
+	ctx = &CrawlerContext{Req: req}
+	got, err = ctx.ResolveURL("2")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/book/2", got)
+
+	ctx = &CrawlerContext{Req: &Request{Url: "://bad"}}
+	_, err = ctx.ResolveURL("2")
+	assert.Error(t, err)
+}
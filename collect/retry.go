@@ -0,0 +1,106 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// StatusError 非200响应错误，携带状态码供重试策略判断
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error http status:%v", e.Status)
+}
+
+// defaultRetryStatusCodes 未配置时默认可重试的状态码
+var defaultRetryStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy 请求失败重试策略
+// 第n次重试前等待 BaseDelay * Multiplier^(n-1)，不超过MaxDelay，并按Jitter比例随机抖动
+type RetryPolicy struct {
+	MaxAttempts int     `json:"max_attempts"` // 最大尝试次数（含首次请求），<=0 表示未配置
+	BaseDelay   int     `json:"base_delay"`   // 首次重试等待时间，毫秒
+	MaxDelay    int     `json:"max_delay"`    // 最大等待时间，毫秒
+	Multiplier  float64 `json:"multiplier"`   // 退避倍数
+	Jitter      float64 `json:"jitter"`       // 抖动比例，0~1
+	StatusCodes []int   `json:"status_codes"` // 可重试的http状态码
+	// Retryable 自定义错误是否可重试，设置后优先于StatusCodes
+	Retryable func(error) bool `json:"-"`
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   1000,
+	MaxDelay:    60000,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// Enabled 是否配置了重试策略
+func (p RetryPolicy) Enabled() bool {
+	return p.MaxAttempts > 0
+}
+
+// ShouldRetry 第attempts次尝试失败后是否还需要重试
+func (p RetryPolicy) ShouldRetry(attempts int, err error) bool {
+	if attempts >= p.MaxAttempts {
+		return false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		codes := p.StatusCodes
+		if len(codes) == 0 {
+			codes = defaultRetryStatusCodes
+		}
+		for _, code := range codes {
+			if code == se.StatusCode {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// Backoff 第attempt次重试前的等待时间
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay = delay * (1 - jitter + 2*jitter*rand.Float64())
+	}
+
+	return time.Duration(delay) * time.Millisecond
+}
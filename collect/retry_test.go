@@ -0,0 +1,50 @@
+package collect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3}
+	tests := []struct {
+		name     string
+		attempts int
+		err      error
+		want     bool
+	}{
+		{"network error", 1, errors.New("connection reset"), true},
+		{"max attempts", 3, errors.New("connection reset"), false},
+		{"retryable status", 1, &StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"not retryable status", 1, &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"canceled", 1, context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.ShouldRetry(tt.attempts, tt.err))
+		})
+	}
+
+	p.StatusCodes = []int{http.StatusNotFound}
+	assert.True(t, p.ShouldRetry(1, &StatusError{StatusCode: http.StatusNotFound}))
+	assert.False(t, p.ShouldRetry(1, &StatusError{StatusCode: http.StatusServiceUnavailable}))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100, MaxDelay: 1000, Multiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, p.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, p.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, p.Backoff(3))
+	assert.Equal(t, time.Second, p.Backoff(10))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.Backoff(1)
+		assert.True(t, d >= 50*time.Millisecond && d <= 150*time.Millisecond, d)
+	}
+}
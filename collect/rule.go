@@ -1,6 +1,8 @@
 package collect
 
 import (
+	"net/http"
+	"net/url"
 	"regexp"
 	"time"
 
@@ -13,16 +15,36 @@ type RuleTree struct {
 	Root func() ([]*Request,error)
 	// Trunk 规则哈希表
 	Trunk map[string]*Rule
+	// Auth 爬取前的登录步骤，为空时不登录
+	// ParseFunc返回ErrSessionExpired时会重新登录并重试请求
+	Auth Authenticator
 }
 
 type Rule struct {
 	ItemFields []string
 	ParseFunc func(*CrawlerContext) (ParseResult,error)
+	// AllowStatus 除200外交给ParseFunc处理的状态码，如404
+	AllowStatus []int
+}
+
+// Allowed 状态码是否交给ParseFunc处理
+func (r *Rule) Allowed(statusCode int) bool {
+	if statusCode == http.StatusOK {
+		return true
+	}
+	for _, code := range r.AllowStatus {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
 }
 
 type CrawlerContext struct {
 	Body []byte
 	Req  *Request
+	// Resp 请求的响应，Body与Resp.Body相同
+	Resp *Response
 }
 
 type RuleMode struct {
@@ -34,6 +56,22 @@ type OutputData struct {
 	Data map[string]any
 }
 
+// ResolveURL 以响应的最终地址为基准解析相对链接，没有响应时以请求地址为基准
+func (c *CrawlerContext) ResolveURL(ref string) (string, error) {
+	if c.Resp != nil && c.Resp.URL != nil {
+		return c.Resp.ResolveURL(ref)
+	}
+	base, err := url.Parse(c.Req.Url)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
 func (c *CrawlerContext) GetRule(ruleName string) *Rule {
 	return c.Req.Task.Rule.Trunk[ruleName]
 }
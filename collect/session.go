@@ -0,0 +1,226 @@
+package collect
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Account 任务使用的账号，Cookie为账号初始的cookie，格式与Cookie请求头相同
+type Account struct {
+	Name     string `json:"name"`
+	Cookie   string `json:"cookie"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Session 基于cookiejar的会话，保存响应中的Set-Cookie并在之后的请求中携带
+// 实现了http.CookieJar，可直接用作http.Client的Jar
+type Session struct {
+	Account Account
+	jar     *cookiejar.Jar
+	// seed 账号初始的cookie，在jar中没有同名cookie时携带
+	seed    []*http.Cookie
+	mu      sync.Mutex
+	records map[string]sessionRecord
+	auth    authState
+}
+
+// sessionRecord 记录设置过的cookie，cookiejar无法遍历，持久化时使用
+type sessionRecord struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+func NewSession(account Account) *Session {
+	jar, _ := cookiejar.New(nil)
+	header := http.Header{}
+	header.Set("Cookie", account.Cookie)
+	return &Session{
+		Account: account,
+		jar:     jar,
+		seed:    (&http.Request{Header: header}).Cookies(),
+		records: map[string]sessionRecord{},
+	}
+}
+
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jar.SetCookies(u, cookies)
+	now := time.Now()
+	for _, c := range cookies {
+		c := *c
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		domain := c.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		s.records[domain+c.Path+";"+c.Name] = sessionRecord{URL: u.String(), Cookie: &c}
+	}
+}
+
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	s.mu.Lock()
+	jar := s.jar
+	s.mu.Unlock()
+	cookies := jar.Cookies(u)
+	for _, c := range s.seed {
+		if !hasCookie(cookies, c.Name) {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+// Reset 清空会话中的cookie，账号初始的cookie仍保留
+func (s *Session) Reset() {
+	jar, _ := cookiejar.New(nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jar = jar
+	s.records = map[string]sessionRecord{}
+}
+
+func (s *Session) snapshot() []sessionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	records := make([]sessionRecord, 0, len(s.records))
+	for _, r := range s.records {
+		if r.Cookie.MaxAge < 0 || (!r.Cookie.Expires.IsZero() && r.Cookie.Expires.Before(now)) {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func (s *Session) restore(records []sessionRecord) {
+	for _, r := range records {
+		u, err := url.Parse(r.URL)
+		if err != nil || r.Cookie == nil {
+			continue
+		}
+		s.SetCookies(u, []*http.Cookie{r.Cookie})
+	}
+}
+
+func hasCookie(cookies []*http.Cookie, name string) bool {
+	for _, c := range cookies {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionPool 任务的会话池，每个账号一个会话，请求之间轮流使用
+type SessionPool struct {
+	sessions []*Session
+	next     uint32
+}
+
+// NewSessionPool 没有账号时创建一个匿名会话
+func NewSessionPool(accounts ...Account) *SessionPool {
+	if len(accounts) == 0 {
+		accounts = []Account{{}}
+	}
+	p := &SessionPool{}
+	for _, account := range accounts {
+		p.sessions = append(p.sessions, NewSession(account))
+	}
+	return p
+}
+
+// Next 轮询返回下一个会话
+func (p *SessionPool) Next() *Session {
+	n := atomic.AddUint32(&p.next, 1) - 1
+	return p.sessions[n%uint32(len(p.sessions))]
+}
+
+func (p *SessionPool) Sessions() []*Session {
+	return p.sessions
+}
+
+// Key 会话在池中的键，与持久化文件中的键相同
+func (p *SessionPool) Key(s *Session) string {
+	for i, ss := range p.sessions {
+		if ss == s {
+			return sessionKey(ss, i)
+		}
+	}
+	return ""
+}
+
+// Lookup 返回键为key的会话，不存在时返回nil
+func (p *SessionPool) Lookup(key string) *Session {
+	for i, s := range p.sessions {
+		if sessionKey(s, i) == key {
+			return s
+		}
+	}
+	return nil
+}
+
+// sessionKey 持久化时区分会话，没有账号名称时使用序号
+func sessionKey(s *Session, i int) string {
+	if s.Account.Name != "" {
+		return s.Account.Name
+	}
+	if s.Account.Username != "" {
+		return s.Account.Username
+	}
+	return "#" + strconv.Itoa(i)
+}
+
+// Load 从文件恢复各会话的cookie，文件不存在时忽略
+func (p *SessionPool) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	saved := map[string][]sessionRecord{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return err
+	}
+	for i, s := range p.sessions {
+		s.restore(saved[sessionKey(s, i)])
+	}
+	return nil
+}
+
+// Save 将各会话未过期的cookie保存到文件
+func (p *SessionPool) Save(path string) error {
+	saved := map[string][]sessionRecord{}
+	for i, s := range p.sessions {
+		saved[sessionKey(s, i)] = s.snapshot()
+	}
+	b, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,67 @@
+package collect
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionPool(t *testing.T) {
+	u, _ := url.Parse("https://www.douban.com/group/")
+	pool := NewSessionPool(
+		Account{Name: "a", Cookie: "bid=1; ck=a"},
+		Account{Name: "b", Cookie: "bid=2"},
+	)
+	a, b := pool.Next(), pool.Next()
+	assert.Equal(t, "a", a.Account.Name)
+	assert.Equal(t, "b", b.Account.Name)
+	assert.Same(t, a, pool.Next())
+
+	a.SetCookies(u, []*http.Cookie{
+		{Name: "ck", Value: "refreshed", Path: "/"},
+		{Name: "token", Value: "t", Path: "/", MaxAge: 3600},
+		{Name: "expired", Value: "x", Path: "/", Expires: time.Now().Add(-time.Hour)},
+	})
+	assert.Equal(t, map[string]string{"bid": "1", "ck": "refreshed", "token": "t"}, cookieValues(a.Cookies(u)))
+	assert.Equal(t, map[string]string{"bid": "2"}, cookieValues(b.Cookies(u)))
+
+	path := filepath.Join(t.TempDir(), "sessions.json")
+	require.NoError(t, pool.Save(path))
+	restored := NewSessionPool(
+		Account{Name: "a", Cookie: "bid=1; ck=a"},
+		Account{Name: "b", Cookie: "bid=2"},
+	)
+	require.NoError(t, restored.Load(path))
+	assert.Equal(t, cookieValues(a.Cookies(u)), cookieValues(restored.Next().Cookies(u)))
+
+	a.Reset()
+	assert.Equal(t, map[string]string{"bid": "1", "ck": "a"}, cookieValues(a.Cookies(u)))
+}
+
+func TestRequestSessionKey(t *testing.T) {
+	task := NewTask(WithName("task"), WithStickyProxy(StickyHost))
+	r := &Request{Task: task, Url: "https://book.douban.com/tag/"}
+	assert.Equal(t, "task/host/book.douban.com", r.ProxyKey())
+
+	task.Sessions = NewSessionPool(Account{Name: "a"}, Account{Name: "b"})
+	first := &Request{Task: task, Url: "https://book.douban.com/tag/"}
+	assert.Equal(t, "task/session/a", first.ProxyKey())
+	// 恢复的请求按SessionKey使用原来的会话
+	restored := &Request{Task: task, Url: first.Url, SessionKey: first.SessionKey}
+	assert.Same(t, first.Session(), restored.Session())
+	// 恢复的请求没有从会话池分配新会话
+	assert.Equal(t, "b", task.Sessions.Next().Account.Name)
+}
+
+func cookieValues(cookies []*http.Cookie) map[string]string {
+	values := map[string]string{}
+	for _, c := range cookies {
+		values[c.Name] = c.Value
+	}
+	return values
+}
@@ -1,5 +1,7 @@
 package collect
 
+import "encoding/json"
+
 type Tmp struct{
 	data map[string]any
 }
@@ -15,4 +17,13 @@ func (t *Tmp) Set(key string,val any) error{
 	t.data[key] = val
 
 	return nil
-}
\ No newline at end of file
+}
+
+// MarshalJSON 请求导出、持久化时保留临时数据
+func (t *Tmp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.data)
+}
+
+func (t *Tmp) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &t.data)
+}
@@ -0,0 +1,142 @@
+package collect
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/awaketai/crawler/proxy"
+	"go.uber.org/zap"
+)
+
+// TransportConfig BrowserFetch使用的http.Transport配置，为0的字段使用默认值
+type TransportConfig struct {
+	MaxIdleConns        int `json:"max_idle_conns"`
+	MaxIdleConnsPerHost int `json:"max_idle_conns_per_host"`
+	// MaxConnsPerHost 每个站点的最大连接数，为0时不限制
+	MaxConnsPerHost int `json:"max_conns_per_host"`
+	// IdleConnTimeout 空闲连接的保持时长，单位毫秒
+	IdleConnTimeout int64 `json:"idle_conn_timeout"`
+	// DialTimeout 建立连接的超时时间，单位毫秒
+	DialTimeout int64 `json:"dial_timeout"`
+	// KeepAlive TCP keep-alive的间隔，单位毫秒
+	KeepAlive         int64 `json:"keep_alive"`
+	DisableKeepAlives bool  `json:"disable_keep_alives"`
+	// TLSHandshakeTimeout TLS握手的超时时间，单位毫秒
+	TLSHandshakeTimeout int64 `json:"tls_handshake_timeout"`
+	// ResponseHeaderTimeout 等待响应头的超时时间，单位毫秒，为0时不限制
+	ResponseHeaderTimeout int64 `json:"response_header_timeout"`
+	DisableHTTP2          bool  `json:"disable_http2"`
+	// InsecureSkipVerify 不校验证书，仅用于调试
+	InsecureSkipVerify bool `json:"insecure_skip_verify"`
+	// TLSMinVersion TLS最低版本，可选 1.0 1.1 1.2 1.3，为空时使用Go的默认值
+	TLSMinVersion string `json:"tls_min_version"`
+}
+
+var defaultTransportConfig = TransportConfig{
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 10,
+	IdleConnTimeout:     90000,
+	DialTimeout:         30000,
+	KeepAlive:           30000,
+	TLSHandshakeTimeout: 10000,
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func millis(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+// NewTransport 根据配置创建http.Transport，代理由调用方设置
+func NewTransport(cfg TransportConfig) *http.Transport {
+	def := defaultTransportConfig
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = def.MaxIdleConns
+	}
+	if cfg.MaxIdleConnsPerHost == 0 {
+		cfg.MaxIdleConnsPerHost = def.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout == 0 {
+		cfg.IdleConnTimeout = def.IdleConnTimeout
+	}
+	if cfg.DialTimeout == 0 {
+		cfg.DialTimeout = def.DialTimeout
+	}
+	if cfg.KeepAlive == 0 {
+		cfg.KeepAlive = def.KeepAlive
+	}
+	if cfg.TLSHandshakeTimeout == 0 {
+		cfg.TLSHandshakeTimeout = def.TLSHandshakeTimeout
+	}
+	dialer := &net.Dialer{
+		Timeout:   millis(cfg.DialTimeout),
+		KeepAlive: millis(cfg.KeepAlive),
+	}
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tlsVersions[cfg.TLSMinVersion],
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       millis(cfg.IdleConnTimeout),
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		TLSHandshakeTimeout:   millis(cfg.TLSHandshakeTimeout),
+		ResponseHeaderTimeout: millis(cfg.ResponseHeaderTimeout),
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       tlsConfig,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+	}
+}
+
+type BrowserFetchOption func(b *BrowserFetch)
+
+func WithFetchTimeout(timeout time.Duration) BrowserFetchOption {
+	return func(b *BrowserFetch) {
+		b.Timeout = timeout
+	}
+}
+
+func WithFetchProxy(p proxy.ProxyFunc) BrowserFetchOption {
+	return func(b *BrowserFetch) {
+		b.Proxy = p
+	}
+}
+
+func WithFetchProxyPool(pool *proxy.Pool) BrowserFetchOption {
+	return func(b *BrowserFetch) {
+		b.Pool = pool
+	}
+}
+
+func WithFetchLogger(logger *zap.Logger) BrowserFetchOption {
+	return func(b *BrowserFetch) {
+		b.Logger = logger
+	}
+}
+
+// WithTransport 使用配置创建的Transport
+func WithTransport(cfg TransportConfig) BrowserFetchOption {
+	return func(b *BrowserFetch) {
+		b.transport = NewTransport(cfg)
+	}
+}
+
+// NewBrowserFetch 创建BrowserFetch，所有请求共用同一个Transport以复用连接
+func NewBrowserFetch(opts ...BrowserFetchOption) *BrowserFetch {
+	b := &BrowserFetch{}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.init()
+	return b
+}
@@ -0,0 +1,47 @@
+package collect
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/awaketai/crawler/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrowserFetchTransport(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok " + r.URL.Host))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	defaultProxy := http.DefaultTransport.(*http.Transport).Proxy
+	// 代理服务器收到完整URL的请求
+	pool, err := proxy.NewPool([]string{server.URL})
+	require.NoError(t, err)
+	b := NewBrowserFetch(WithFetchProxyPool(pool), WithTransport(TransportConfig{MaxConnsPerHost: 1}))
+	defer b.CloseIdleConnections()
+
+	task := NewTask(WithName("task"), WithStickyProxy(StickyTask))
+	for i := 0; i < 5; i++ {
+		resp, err := b.Get(context.Background(), &Request{Task: task, Url: "http://example.com/"})
+		require.NoError(t, err)
+		assert.Equal(t, "ok example.com", string(resp.Body))
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+	assert.Equal(t, int64(5), pool.Proxies()[0].Successes)
+	// 不修改http.DefaultTransport
+	assert.Equal(t, reflect.ValueOf(defaultProxy).Pointer(), reflect.ValueOf(http.DefaultTransport.(*http.Transport).Proxy).Pointer())
+}
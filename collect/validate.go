@@ -0,0 +1,281 @@
+package collect
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Verdict 响应校验的结果
+type Verdict int
+
+const (
+	Success Verdict = iota
+	// SoftFailure 响应不完整或内容异常，按重试策略重试
+	SoftFailure
+	// Ban 请求被目标站点封禁，站点冷却一段时间后重试
+	Ban
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Success:
+		return "success"
+	case SoftFailure:
+		return "soft_failure"
+	case Ban:
+		return "ban"
+	}
+	return fmt.Sprintf("verdict(%d)", int(v))
+}
+
+// ParseVerdict 解析配置中的校验结果，为空时为SoftFailure
+func ParseVerdict(s string) (Verdict, error) {
+	switch strings.ToLower(s) {
+	case "", "soft_failure", "soft":
+		return SoftFailure, nil
+	case "ban":
+		return Ban, nil
+	case "success":
+		return Success, nil
+	}
+	return Success, fmt.Errorf("unknown verdict:%s", s)
+}
+
+// ValidationError 响应未通过校验
+type ValidationError struct {
+	Verdict Verdict
+	Reason  string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("response %s: %s", e.Verdict, e.Reason)
+}
+
+// Validator 校验响应，返回校验结果及原因
+type Validator interface {
+	Validate(resp *Response) (Verdict, string)
+}
+
+// ValidatorFunc 函数形式的Validator
+type ValidatorFunc func(resp *Response) (Verdict, string)
+
+func (f ValidatorFunc) Validate(resp *Response) (Verdict, string) {
+	return f(resp)
+}
+
+// Validate 依次执行所有校验，返回最严重的结果
+func Validate(resp *Response, validators []Validator) (Verdict, string) {
+	verdict, reason := Success, ""
+	for _, v := range validators {
+		if got, why := v.Validate(resp); got > verdict {
+			verdict, reason = got, why
+		}
+	}
+	return verdict, reason
+}
+
+// StatusValidator 状态码为codes之一时返回verdict
+func StatusValidator(verdict Verdict, codes ...int) Validator {
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return verdict, fmt.Sprintf("status code %d", code)
+			}
+		}
+		return Success, ""
+	})
+}
+
+// RegexValidator 响应体匹配re时返回verdict，常用于识别封禁页面
+func RegexValidator(re *regexp.Regexp, verdict Verdict) Validator {
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		if re.Match(resp.Body) {
+			return verdict, fmt.Sprintf("body matches %q", re.String())
+		}
+		return Success, ""
+	})
+}
+
+// ContainsValidator 响应体包含s时返回verdict
+func ContainsValidator(s string, verdict Verdict) Validator {
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		if bytes.Contains(resp.Body, []byte(s)) {
+			return verdict, fmt.Sprintf("body contains %q", s)
+		}
+		return Success, ""
+	})
+}
+
+// MinSizeValidator 响应体小于size字节时返回verdict
+func MinSizeValidator(size int, verdict Verdict) Validator {
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		if len(resp.Body) < size {
+			return verdict, fmt.Sprintf("body too short: %d < %d", len(resp.Body), size)
+		}
+		return Success, ""
+	})
+}
+
+// MaxSizeValidator 响应体大于size字节时返回verdict
+func MaxSizeValidator(size int, verdict Verdict) Validator {
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		if len(resp.Body) > size {
+			return verdict, fmt.Sprintf("body too long: %d > %d", len(resp.Body), size)
+		}
+		return Success, ""
+	})
+}
+
+// SelectorValidator 页面中没有匹配selector的元素时返回verdict
+// selector支持标签、#id、.class、[attr]、[attr=value]及其组合，空格表示后代元素
+func SelectorValidator(selector string, verdict Verdict) Validator {
+	sel := parseSelector(selector)
+	return ValidatorFunc(func(resp *Response) (Verdict, string) {
+		doc, err := html.Parse(bytes.NewReader(resp.Body))
+		if err != nil || !sel.find(doc) {
+			return verdict, fmt.Sprintf("selector %q not found", selector)
+		}
+		return Success, ""
+	})
+}
+
+// ValidatorConfig 配置文件中的校验规则
+type ValidatorConfig struct {
+	// Type 可选 status regex contains min_size max_size selector
+	Type string `json:"type"`
+	// Verdict 可选 ban soft_failure，为空时为soft_failure
+	Verdict  string `json:"verdict"`
+	Codes    []int  `json:"codes"`
+	Pattern  string `json:"pattern"`
+	Size     int    `json:"size"`
+	Selector string `json:"selector"`
+}
+
+func (c ValidatorConfig) Validator() (Validator, error) {
+	verdict, err := ParseVerdict(c.Verdict)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Type {
+	case "status":
+		return StatusValidator(verdict, c.Codes...), nil
+	case "regex":
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return RegexValidator(re, verdict), nil
+	case "contains":
+		return ContainsValidator(c.Pattern, verdict), nil
+	case "min_size":
+		return MinSizeValidator(c.Size, verdict), nil
+	case "max_size":
+		return MaxSizeValidator(c.Size, verdict), nil
+	case "selector":
+		return SelectorValidator(c.Selector, verdict), nil
+	}
+	return nil, fmt.Errorf("unknown validator type:%s", c.Type)
+}
+
+// compound 单个元素的选择条件
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   [][2]string
+}
+
+type selector []compound
+
+var compoundRe = regexp.MustCompile(`([#.]?[\w-]+)|\[([\w-]+)(?:=["']?([^"'\]]*)["']?)?\]`)
+
+func parseSelector(s string) selector {
+	var sel selector
+	for _, part := range strings.Fields(s) {
+		var c compound
+		for _, m := range compoundRe.FindAllStringSubmatch(part, -1) {
+			switch {
+			case m[2] != "":
+				c.attrs = append(c.attrs, [2]string{m[2], m[3]})
+			case strings.HasPrefix(m[1], "#"):
+				c.id = m[1][1:]
+			case strings.HasPrefix(m[1], "."):
+				c.classes = append(c.classes, m[1][1:])
+			default:
+				c.tag = strings.ToLower(m[1])
+			}
+		}
+		sel = append(sel, c)
+	}
+	return sel
+}
+
+// find 是否存在匹配选择器的元素，前面的条件匹配祖先元素
+func (sel selector) find(n *html.Node) bool {
+	if len(sel) == 0 {
+		return true
+	}
+	rest := sel
+	if n.Type == html.ElementNode && sel[0].match(n) {
+		rest = sel[1:]
+		if len(rest) == 0 {
+			return true
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if rest.find(child) {
+			return true
+		}
+		// 当前元素匹配时，后代元素仍可能匹配第一个条件
+		if len(rest) < len(sel) && sel.find(child) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) match(n *html.Node) bool {
+	if c.tag != "" && c.tag != n.Data {
+		return false
+	}
+	attr := func(key string) (string, bool) {
+		for _, a := range n.Attr {
+			if a.Key == key {
+				return a.Val, true
+			}
+		}
+		return "", false
+	}
+	if c.id != "" {
+		if id, _ := attr("id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := attr("class")
+		fields := strings.Fields(class)
+		for _, want := range c.classes {
+			found := false
+			for _, f := range fields {
+				if f == want {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, kv := range c.attrs {
+		v, ok := attr(kv[0])
+		if !ok || (kv[1] != "" && v != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package collect
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	page := `<html><body><div id="content" class="main wide"><ul class="list"><li><a href="/a" data-id="1">a</a></li></ul></div></body></html>`
+	tests := []struct {
+		name       string
+		resp       *Response
+		validators []Validator
+		want       Verdict
+	}{
+		{"no validators", &Response{StatusCode: 200}, nil, Success},
+		{"status ban", &Response{StatusCode: 403}, []Validator{StatusValidator(Ban, 403, 429)}, Ban},
+		{"status ok", &Response{StatusCode: 200}, []Validator{StatusValidator(Ban, 403)}, Success},
+		{"contains", &Response{Body: []byte("请输入验证码")}, []Validator{ContainsValidator("验证码", Ban)}, Ban},
+		{"regex", &Response{Body: []byte("captcha-123")}, []Validator{RegexValidator(regexp.MustCompile(`captcha-\d+`), SoftFailure)}, SoftFailure},
+		{"min size", &Response{Body: []byte("short")}, []Validator{MinSizeValidator(10, SoftFailure)}, SoftFailure},
+		{"max size", &Response{Body: []byte("long body")}, []Validator{MaxSizeValidator(4, SoftFailure)}, SoftFailure},
+		{"most severe wins", &Response{StatusCode: 403, Body: []byte("x")}, []Validator{
+			MinSizeValidator(10, SoftFailure),
+			StatusValidator(Ban, 403),
+		}, Ban},
+		{"selector found", &Response{Body: []byte(page)}, []Validator{SelectorValidator("div#content.main ul.list a[data-id=1]", SoftFailure)}, Success},
+		{"selector descendant", &Response{Body: []byte(page)}, []Validator{SelectorValidator("body li a[href]", SoftFailure)}, Success},
+		{"selector missing", &Response{Body: []byte(page)}, []Validator{SelectorValidator("div.sidebar a", SoftFailure)}, SoftFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := Validate(tt.resp, tt.validators)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidatorConfig(t *testing.T) {
+	v, err := ValidatorConfig{Type: "status", Verdict: "ban", Codes: []int{403}}.Validator()
+	assert.NoError(t, err)
+	got, _ := v.Validate(&Response{StatusCode: 403})
+	assert.Equal(t, Ban, got)
+
+	_, err = ValidatorConfig{Type: "unknown"}.Validator()
+	assert.Error(t, err)
+	_, err = ValidatorConfig{Type: "regex", Pattern: "("}.Validator()
+	assert.Error(t, err)
+	_, err = ValidatorConfig{Type: "min_size", Verdict: "maybe"}.Validator()
+	assert.Error(t, err)
+}
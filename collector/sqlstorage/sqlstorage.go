@@ -87,6 +87,7 @@ func (s *SqlStore) getFields(cell *collector.DataCell) []sqldb.Field {
 	return columnNames
 }
 
+// Flush 写入缓存的数据，写入成功后清空缓存，失败时保留缓存以便下次Flush重试
 func (s *SqlStore) Flush() error {
 	if len(s.dataDocker) == 0 {
 		return nil
@@ -137,7 +138,9 @@ func (s *SqlStore) Flush() error {
 	})
 	if err != nil {
 		s.logger.Error("insert data failed", zap.Error(err))
+		return err
 	}
+	s.dataDocker = nil
 
 	return nil
 }
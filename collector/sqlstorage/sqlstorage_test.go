@@ -1,6 +1,7 @@
 package sqlstorage
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/awaketai/crawler/collector"
@@ -9,6 +10,7 @@ import (
 )
 
 type mysqldb struct {
+	insertErr error
 }
 
 func (m *mysqldb) CreateTable(t sqldb.TableData) error {
@@ -16,7 +18,7 @@ func (m *mysqldb) CreateTable(t sqldb.TableData) error {
 }
 
 func (m *mysqldb) Insert(t sqldb.TableData) error {
-	return nil
+	return m.insertErr
 }
 
 func TestSQLStorage(t *testing.T) {
@@ -24,10 +26,24 @@ func TestSQLStorage(t *testing.T) {
 		dataDocker []*collector.DataCell
 		options    options
 	}
+	book := func() *collector.DataCell {
+		return &collector.DataCell{
+			Data: map[string]interface{}{
+				"Task": "douban_book_list",
+				"Rule": "书籍简介",
+				"Data": map[string]any{"书名": "test"},
+				"Url":  "https://book.douban.com/subject/1/",
+				"Time": "2024-01-01 00:00:00",
+			},
+		}
+	}
 	tests := []struct {
-		name    string
-		fields  fields
-		wantErr bool
+		name      string
+		fields    fields
+		insertErr error
+		wantErr   bool
+		// wantKept Flush后仍保留在缓存中的数据条数
+		wantKept int
 	}{
 		{
 			name:    "empty",
@@ -45,7 +61,25 @@ func TestSQLStorage(t *testing.T) {
 					},
 				},
 			},
-			wantErr: true,
+			wantErr:  true,
+			wantKept: 1,
+		},
+		{
+			name: "insert",
+			fields: fields{
+				dataDocker: []*collector.DataCell{book(), book()},
+				options:    defaultOptions,
+			},
+		},
+		{
+			name: "insert failed",
+			fields: fields{
+				dataDocker: []*collector.DataCell{book(), book()},
+				options:    defaultOptions,
+			},
+			insertErr: errors.New("connection refused"),
+			wantErr:   true,
+			wantKept:  2,
 		},
 	}
 
@@ -54,13 +88,13 @@ func TestSQLStorage(t *testing.T) {
 			// Add your test logic here
 			s := &SqlStore{
 				dataDocker: tt.fields.dataDocker,
-				db:         &mysqldb{},
+				db:         &mysqldb{insertErr: tt.insertErr},
 				options:    tt.fields.options,
 			}
 			if err := s.Flush(); (err != nil) != tt.wantErr {
 				t.Errorf("SqlStore.Flush() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			assert.Nil(t, s.dataDocker)
+			assert.Len(t, s.dataDocker, tt.wantKept)
 		})
 	}
 }
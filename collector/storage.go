@@ -2,6 +2,8 @@ package collector
 
 type Storager interface{
 	Save(datas ...*DataCell) error
+	// Flush 写出缓存中尚未保存的数据
+	Flush() error
 }
 
 type DataCell struct{
@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 周期调度，Next返回t之后的下一次执行时间
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// Parse 解析cron表达式
+// 支持标准的5段格式：分 时 日 月 周，每段可使用 * , - /
+// 以及 @yearly @monthly @weekly @daily @hourly 和 @every <duration>
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("parse cron %q: %w", spec, err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("parse cron %q: interval must be at least 1s", spec)
+		}
+		return every(d), nil
+	}
+	if descriptor, ok := descriptors[spec]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("parse cron %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	s := &specSchedule{}
+	var err error
+	for i, b := range bounds {
+		var bits uint64
+		if bits, err = parseField(fields[i], b); err != nil {
+			return nil, fmt.Errorf("parse cron %q: %w", spec, err)
+		}
+		switch i {
+		case 0:
+			s.minute = bits
+		case 1:
+			s.hour = bits
+		case 2:
+			s.dom = bits
+			s.domStar = fields[i] == "*"
+		case 3:
+			s.month = bits
+		case 4:
+			// 周日可以写作0或7
+			if bits&(1<<7) != 0 {
+				bits |= 1
+			}
+			s.dow = bits
+			s.dowStar = fields[i] == "*"
+		}
+	}
+
+	return s, nil
+}
+
+var descriptors = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+type bound struct {
+	name     string
+	min, max int
+}
+
+var bounds = []bound{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseField 解析单个字段，返回可取值的位图
+func parseField(field string, b bound) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field: %q", b.name, part)
+			}
+			rangePart, step = part[:i], n
+		}
+		start, end := b.min, b.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			lo, hi, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(lo); err != nil {
+				return 0, fmt.Errorf("invalid %s field: %q", b.name, part)
+			}
+			if end, err = strconv.Atoi(hi); err != nil {
+				return 0, fmt.Errorf("invalid %s field: %q", b.name, part)
+			}
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid %s field: %q", b.name, part)
+			}
+			start, end = n, n
+			// 5/10 表示从5开始每10个单位
+			if step > 1 {
+				end = b.max
+			}
+		}
+		if start < b.min || end > b.max || start > end {
+			return 0, fmt.Errorf("%s field out of range [%d,%d]: %q", b.name, b.min, b.max, part)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+type specSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// Next 逐级跳过不匹配的月、日、时、分，最多向后查找5年
+func (s *specSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	yearLimit := t.Year() + 5
+	for t.Year() <= yearLimit {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches 日和周都有限制时满足其一即可，与标准cron一致
+func (s *specSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e)).Truncate(time.Second)
+}
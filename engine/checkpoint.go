@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LoadVisited 从检查点文件恢复已访问集合，文件不存在时忽略
+func (c *Crawler) LoadVisited(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var visited []string
+	if err := json.Unmarshal(b, &visited); err != nil {
+		return err
+	}
+	c.VisitedLock.Lock()
+	defer c.VisitedLock.Unlock()
+	for _, unique := range visited {
+		c.Visited[unique] = true
+	}
+
+	return nil
+}
+
+// SaveVisited 将已访问集合写入检查点文件，先写临时文件再替换，避免写入中断损坏检查点
+func (c *Crawler) SaveVisited(path string) error {
+	c.VisitedLock.Lock()
+	visited := make([]string, 0, len(c.Visited))
+	for unique := range c.Visited {
+		visited = append(visited, unique)
+	}
+	c.VisitedLock.Unlock()
+	b, err := json.Marshal(visited)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// checkpoint 定期保存已访问集合，ctx取消后返回
+func (c *Crawler) checkpoint(ctx context.Context) {
+	ticker := time.NewTicker(c.CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.SaveVisited(c.CheckpointPath); err != nil {
+				c.Logger.Error("save visited checkpoint failed", zap.Error(err))
+			}
+		}
+	}
+}
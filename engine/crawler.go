@@ -2,12 +2,18 @@ package engine
 
 import (
 	"context"
-	"strings"
+	"crypto/md5"
+	"errors"
+	"net/http"
+	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/awaketai/crawler/collect"
 	"github.com/awaketai/crawler/collector"
+	"github.com/awaketai/crawler/cron"
+	"github.com/awaketai/crawler/robots"
 	"go.uber.org/zap"
 )
 
@@ -15,9 +21,17 @@ type Crawler struct {
 	out         chan collect.ParseResult
 	Visited     map[string]bool
 	VisitedLock sync.Mutex
-	// failures 失败尝试队列
-	failures    map[string]*collect.Request
-	failureLock sync.Mutex
+	// deadLetters 重试耗尽的失败请求
+	deadLetters *DeadLetterQueue
+	// track 未完成的请求
+	track   *tracker
+	pushing sync.WaitGroup
+	// stopped Run不再调度新请求后为true
+	stopped atomic.Bool
+	stats   *crawlStats
+	// digests 页面内容摘要，用于ChangedOnly任务判断内容是否变化
+	digests    map[string][md5.Size]byte
+	digestLock sync.Mutex
 	options
 }
 
@@ -30,23 +44,106 @@ func NewCrawler(opts ...Option) *Crawler {
 		out:         make(chan collect.ParseResult),
 		Visited:     map[string]bool{},
 		VisitedLock: sync.Mutex{},
-		failures:    map[string]*collect.Request{},
+		deadLetters: NewDeadLetterQueue(),
+		track:       newTracker(),
+		stats:       newCrawlStats(),
+		digests:     map[string][md5.Size]byte{},
 	}
 	c.options = options
+	if c.robots == nil {
+		c.robots = robots.NewCache()
+	}
 
 	return c
 }
 
-func (c *Crawler) Run() {
-	go c.Schedule()
+// Run 运行爬虫直到所有请求处理完毕或ctx取消
+// 停止时不再调度新请求，在ShutdownTimeout内等待进行中的请求完成，超时则取消这些请求，
+// 最后刷新所有存储中缓存的数据并返回本次运行的统计
+func (c *Crawler) Run(ctx context.Context) Summary {
+	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// fetchCtx 与ctx分离，保证停止调度后进行中的请求仍可完成
+	fetchCtx, cancelFetch := context.WithCancel(context.Background())
+	defer cancelFetch()
+
+	if c.CheckpointPath != "" {
+		if err := c.LoadVisited(c.CheckpointPath); err != nil {
+			c.Logger.Error("load visited checkpoint failed", zap.Error(err))
+		}
+		go c.checkpoint(ctx)
+	}
+	c.Schedule(ctx)
+	var wg sync.WaitGroup
 	for i := 0; i < c.WorkCount; i++ {
-		go c.CreateWork()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.CreateWork(ctx, fetchCtx)
+		}()
+	}
+	resultDone := make(chan struct{})
+	go func() {
+		defer close(resultDone)
+		c.HandleResult()
+	}()
+
+	completed := false
+	select {
+	case <-ctx.Done():
+		c.Logger.Info("crawler stopping, wait for in-flight requests", zap.Duration("timeout", c.ShutdownTimeout))
+	case <-c.track.Idle():
+		completed = true
+		c.Logger.Info("crawl completed")
 	}
-	c.HandleResult()
+	cancel()
+	c.stopped.Store(true)
+	workDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workDone)
+	}()
+	select {
+	case <-workDone:
+	case <-time.After(c.ShutdownTimeout):
+		c.Logger.Warn("shutdown timeout, cancel in-flight requests")
+		cancelFetch()
+		<-workDone
+	}
+	c.pushing.Wait()
+	close(c.out)
+	<-resultDone
+	c.flush()
+	c.saveSessions()
+	if c.CheckpointPath != "" {
+		if err := c.SaveVisited(c.CheckpointPath); err != nil {
+			c.Logger.Error("save visited checkpoint failed", zap.Error(err))
+		}
+	}
+
+	summary := c.summary(start, completed)
+	c.Logger.Info("crawler stopped", zap.Any("summary", summary))
+	return summary
 }
 
-func (c *Crawler) Schedule() {
+func (c *Crawler) Schedule(ctx context.Context) {
 	var reqs = make([]*collect.Request, 0, len(c.Seeds))
+	periodic := map[*collect.Task]cron.Schedule{}
+	// 先恢复上次运行未完成的请求，这些请求可能已被记为访问过
+	restored, err := c.scheduler.Restore()
+	if err != nil {
+		c.Logger.Error("restore requests failed", zap.Error(err))
+	}
+	if len(restored) > 0 {
+		c.Logger.Info("restore requests", zap.Int("count", len(restored)))
+		c.VisitedLock.Lock()
+		for _, req := range restored {
+			delete(c.Visited, req.Unique())
+		}
+		c.VisitedLock.Unlock()
+		reqs = append(reqs, restored...)
+	}
 	for _, seed := range c.Seeds {
 		// 获取初始化任务
 		task := Store.hash[seed.Name]
@@ -58,6 +155,32 @@ func (c *Crawler) Schedule() {
 		task.Storage = seed.Storage
 		task.Logger = c.Logger
 		task.Limit = seed.Limit
+		task.RuleLimits = seed.RuleLimits
+		task.Proxy = seed.Proxy
+		task.StickyProxy = seed.StickyProxy
+		task.WaitTime = seed.WaitTime
+		if seed.Retry.Enabled() {
+			task.Retry = seed.Retry
+		}
+		task.Cookie = seed.Cookie
+		c.initSessions(task, seed)
+		task.IgnoreRobots = seed.IgnoreRobots
+		// 任务定义中的校验规则可被种子中配置的覆盖
+		if len(seed.Validators) > 0 {
+			task.Validators = seed.Validators
+		}
+		task.BanCoolDown = seed.BanCoolDown
+		task.OnBan = seed.OnBan
+		task.Cron = seed.Cron
+		task.ChangedOnly = seed.ChangedOnly
+		if task.Cron != "" {
+			schedule, err := cron.Parse(task.Cron)
+			if err != nil {
+				c.Logger.Error("parse task cron failed", zap.String("seed_name", seed.Name), zap.Error(err))
+			} else {
+				periodic[task] = schedule
+			}
+		}
 		rootReqs, err := task.Rule.Root()
 		if err != nil {
 			c.Logger.Error("task rule root err:", zap.String("seed_name", seed.Name), zap.Error(err))
@@ -70,92 +193,204 @@ func (c *Crawler) Schedule() {
 		reqs = append(reqs, rootReqs...)
 	}
 
-	go c.scheduler.Schedule()
-	go c.scheduler.Push(reqs...)
+	go c.scheduler.Schedule(ctx)
+	c.push(reqs...)
+	for task, schedule := range periodic {
+		c.recrawl(ctx, task, schedule)
+	}
+	// 种子没有产生任何请求时直接结束
+	c.track.Add(0)
+}
+
+// push 新请求加入调度，超过深度、已访问以及robots.txt禁止访问的请求被丢弃
+func (c *Crawler) push(reqs ...*collect.Request) {
+	reqs = c.allowed(c.unvisited(reqs))
+	if len(reqs) == 0 {
+		return
+	}
+	c.track.Add(len(reqs))
+	c.enqueue(reqs...)
 }
 
-func (c *Crawler) CreateWork() {
-	for {
-		r := c.scheduler.Pull()
+// unvisited 过滤超过深度与已访问的请求，避免这些请求在调度时占用站点的请求间隔
+// 同一批中重复的请求只保留一个，轮询请求与Reload任务的请求不去重
+func (c *Crawler) unvisited(reqs []*collect.Request) []*collect.Request {
+	kept := make([]*collect.Request, 0, len(reqs))
+	seen := map[string]bool{}
+	for _, r := range reqs {
 		if err := r.Check(); err != nil {
-			c.Logger.Error("check failed", zap.Error(err))
-			continue
-		}
-		// 检测是否已访问过当前请求
-		if !r.Task.Reload && c.HasVisited(r) {
-			c.Logger.Error("requested has visited", zap.String("url", r.Url))
+			c.Logger.Error("check failed", zap.String("url", r.Url), zap.Error(err))
+			c.stats.add(r.Task.Name, TaskStats{Dropped: 1})
 			continue
 		}
-
-		c.StoreVisited(r)
-		var (
-			body []byte
-			err  error
-		)
-		if r.Test && len(r.TestBody) > 0 {
-			body = r.TestBody
-		} else {
-			c.Logger.Info("fetching", zap.String("url", r.Url))
-			body, err = r.Fetch(context.Background())
-			if err != nil {
-				c.Logger.Error("fetch failed", zap.Error(err))
-				c.SetFailure(r)
+		if !r.Task.Reload && !r.Poll {
+			unique := r.Unique()
+			if seen[unique] || c.HasVisited(r) {
+				c.Logger.Info("request visited, drop", zap.String("url", r.Url))
+				c.stats.add(r.Task.Name, TaskStats{Dropped: 1})
 				continue
 			}
+			seen[unique] = true
 		}
-		strBody := string(body)
-		if strings.Contains(strBody, "你访问豆瓣的方式有点像机器人程序") {
-			c.Logger.Error("fetch be banned", zap.String("url", r.Url))
-			c.SetFailure(r)
-			continue
+		kept = append(kept, r)
+	}
+
+	return kept
+}
+
+// enqueue 请求加入调度器，Run返回前会等待所有enqueue完成
+func (c *Crawler) enqueue(reqs ...*collect.Request) {
+	c.pushing.Add(1)
+	go func() {
+		defer c.pushing.Done()
+		c.scheduler.Push(reqs...)
+	}()
+}
+
+// Done 所有请求处理完毕后关闭
+func (c *Crawler) Done() <-chan struct{} {
+	return c.track.Idle()
+}
+
+// CreateWork 从调度器获取请求并处理，ctx取消后不再获取新请求
+// fetchCtx 用于控制进行中的请求
+func (c *Crawler) CreateWork(ctx context.Context, fetchCtx context.Context) {
+	for {
+		r, err := c.scheduler.Pull(ctx)
+		if err != nil {
+			return
 		}
+		c.handle(fetchCtx, r)
+	}
+}
 
-		if len(body) < 6000 {
-			c.Logger.Error("fetch body too short",
-				zap.Int("length", len(body)),
-				zap.String("url", r.Url),
-				zap.String("body", string(body)),
-			)
-			continue
+func (c *Crawler) handle(ctx context.Context, r *collect.Request) {
+	if err := r.Check(); err != nil {
+		c.Logger.Error("check failed", zap.Error(err))
+		c.finish(r, TaskStats{Dropped: 1})
+		return
+	}
+	// 检测是否已访问过当前请求，轮询请求除外
+	if !r.Task.Reload && !r.Poll && c.HasVisited(r) {
+		c.Logger.Error("requested has visited", zap.String("url", r.Url))
+		c.finish(r, TaskStats{Dropped: 1})
+		return
+	}
+	// robots.txt可能在请求排队期间更新或恢复，分配时再次检查
+	ok, until, robotsErr := c.checkRobots(r)
+	if !until.IsZero() {
+		c.deferRobots(r, until, robotsErr)
+		return
+	}
+	if !ok {
+		c.Logger.Warn("disallowed by robots.txt", zap.String("url", r.Url))
+		c.finish(r, TaskStats{Dropped: 1})
+		return
+	}
+
+	c.StoreVisited(r)
+	// 获取当前任务对应的规则
+	rule := r.Task.Rule.Trunk[r.RuleName]
+	var (
+		resp *collect.Response
+		gen  int
+		err  error
+	)
+	if r.Test && len(r.TestBody) > 0 {
+		resp = &collect.Response{StatusCode: http.StatusOK, Body: r.TestBody}
+		resp.URL, _ = url.Parse(r.Url)
+	} else {
+		if gen, err = c.login(ctx, r); err != nil {
+			c.Logger.Error("login failed", zap.String("task", r.Task.Name), zap.Error(err))
+			c.SetFailure(r, err)
+			return
 		}
-		// 获取当前任务对应的规则
-		rule := r.Task.Rule.Trunk[r.RuleName]
-		result, _ := rule.ParseFunc(&collect.CrawlerContext{
-			Body: body,
-			Req:  r,
-		})
-		// 新任务加入队列中
-		if len(result.Requests) > 0 {
-			go c.scheduler.Push(result.Requests...)
+		c.Logger.Info("fetching", zap.String("url", r.Url))
+		resp, err = r.Fetch(ctx)
+	}
+	verdict, reason := validate(rule, resp, r.Task.Validators)
+	c.feedback(r, resp, verdict, err)
+	if verdict != collect.Success {
+		c.reject(r, resp, &collect.ValidationError{Verdict: verdict, Reason: reason})
+		return
+	}
+	var statusErr *collect.StatusError
+	// 规则允许的状态码交给规则处理
+	if err != nil && !(errors.As(err, &statusErr) && resp != nil && rule != nil && rule.Allowed(statusErr.StatusCode)) {
+		c.Logger.Error("fetch failed", zap.Error(err))
+		c.SetFailure(r, err)
+		return
+	}
+	body := resp.Body
+	if r.Task.ChangedOnly && !c.changed(r, body) {
+		c.Logger.Info("content unchanged, skip parse", zap.String("url", r.Url))
+		c.finish(r, TaskStats{Requests: 1, Unchanged: 1})
+		return
+	}
+	result, err := rule.ParseFunc(&collect.CrawlerContext{
+		Body: body,
+		Req:  r,
+		Resp: resp,
+	})
+	if err != nil {
+		// 会话过期时重新登录后重试
+		if errors.Is(err, collect.ErrSessionExpired) && r.Session() != nil {
+			c.Logger.Warn("session expired", zap.String("url", r.Url))
+			r.Session().Expire(gen)
+			c.SetFailure(r, err)
+			return
 		}
-
-		c.out <- result
+		c.Logger.Error("parse failed", zap.String("url", r.Url), zap.Error(err))
+	}
+	// 新任务加入队列中
+	if len(result.Requests) > 0 {
+		c.push(result.Requests...)
 	}
+
+	c.out <- result
+	c.finish(r, TaskStats{Requests: 1, Items: int64(len(result.Items))})
+}
+
+// finish 请求处理结束，记录统计
+func (c *Crawler) finish(r *collect.Request, delta TaskStats) {
+	c.stats.add(r.Task.Name, delta)
+	c.scheduler.Ack(r)
+	c.track.Done()
 }
 
-// HandleResult 如何没有数据，直接循环会导致dead lock
-// 此处使用select {}规避
+// HandleResult 处理解析结果，c.out关闭后返回
 func (c *Crawler) HandleResult() {
-	for {
-		select {
-		case res := <-c.out:
-			for _, item := range res.Items {
-				// 数据存储
-				c.Logger.Info("item:", zap.Any("item", item))
-				switch d := item.(type) {
-				case *collector.DataCell:
-					name := d.GetTaskName()
-					task := Store.hash[name]
-					c.Logger.Info("data cell:", zap.String("task", name), zap.Any("data", d))
-					err := task.Storage.Save(d)
-					if err != nil {
-						c.Logger.Error("storage save failed", zap.Error(err))
-					}
+	for res := range c.out {
+		for _, item := range res.Items {
+			// 数据存储
+			c.Logger.Info("item:", zap.Any("item", item))
+			switch d := item.(type) {
+			case *collector.DataCell:
+				name := d.GetTaskName()
+				task := Store.hash[name]
+				c.Logger.Info("data cell:", zap.String("task", name), zap.Any("data", d))
+				err := task.Storage.Save(d)
+				if err != nil {
+					c.Logger.Error("storage save failed", zap.Error(err))
 				}
 			}
-			// 防止cpu空转，避免忙等
-		case <-time.After(10 * time.Second):
-			c.Logger.Info("no data")
+		}
+	}
+}
+
+// flush 刷新所有任务存储中缓存的数据
+func (c *Crawler) flush() {
+	flushed := map[collector.Storager]struct{}{}
+	for _, seed := range c.Seeds {
+		if seed.Storage == nil {
+			continue
+		}
+		if _, ok := flushed[seed.Storage]; ok {
+			continue
+		}
+		flushed[seed.Storage] = struct{}{}
+		if err := seed.Storage.Flush(); err != nil {
+			c.Logger.Error("storage flush failed", zap.String("task", seed.Name), zap.Error(err))
 		}
 	}
 }
@@ -176,19 +411,103 @@ func (c *Crawler) StoreVisited(reqs ...*collect.Request) {
 	}
 }
 
-func (c *Crawler) SetFailure(req *collect.Request) {
+// SetFailure 按任务的重试策略延迟重试失败请求，重试耗尽后加入死信队列
+// 停止时被取消的请求不计入失败
+func (c *Crawler) SetFailure(req *collect.Request, err error) {
 	if !req.Task.Reload {
 		c.VisitedLock.Lock()
 		unique := req.Unique()
 		delete(c.Visited, unique)
 		c.VisitedLock.Unlock()
 	}
-	c.failureLock.Lock()
-	defer c.failureLock.Unlock()
-	if _, ok := c.failures[req.Unique()]; !ok {
-		c.failures[req.Unique()] = req
-		c.scheduler.Push(req)
+	// 停止时被取消的请求保持未完成，开启持久化时下次运行恢复
+	if errors.Is(err, context.Canceled) {
+		c.Logger.Warn("request canceled, keep pending", zap.String("url", req.Url))
+		return
 	}
-	// todo 失败两次，加入失败队列中
+	policy := c.retryPolicy(req)
+	req.Retries++
+	if !policy.ShouldRetry(req.Retries, err) {
+		c.Logger.Error("request moved to dead letter queue",
+			zap.String("url", req.Url),
+			zap.Int("attempts", req.Retries),
+			zap.Error(err),
+		)
+		c.deadLetters.Add(req, err)
+		c.finish(req, TaskStats{Failures: 1, DeadLetters: 1})
+		return
+	}
+	// 重试的请求仍处于未完成状态
+	c.stats.add(req.Task.Name, TaskStats{Failures: 1, Retries: 1})
+	c.scheduler.Release(req)
+	delay := policy.Backoff(req.Retries)
+	c.Logger.Info("retry request",
+		zap.String("url", req.Url),
+		zap.Int("attempts", req.Retries),
+		zap.Duration("delay", delay),
+	)
+	req.NotBefore = time.Now().Add(delay)
+	c.enqueue(req)
+}
+
+// retryPolicy 请求所属任务的重试策略，任务未配置时使用爬虫的默认策略
+func (c *Crawler) retryPolicy(req *collect.Request) collect.RetryPolicy {
+	if req.Task.Retry.Enabled() {
+		return req.Task.Retry
+	}
+	return c.RetryPolicy
+}
 
+// deferRobots robots.txt暂时无法获取时推迟请求到until
+// 每次推迟计为一次失败的尝试，超过重试策略的次数后加入死信队列，避免robots.txt一直无法获取时爬虫无法结束
+func (c *Crawler) deferRobots(req *collect.Request, until time.Time, err error) {
+	req.Retries++
+	if !c.retryPolicy(req).ShouldRetry(req.Retries, err) {
+		c.Logger.Error("robots.txt unavailable, request moved to dead letter queue",
+			zap.String("url", req.Url),
+			zap.Int("attempts", req.Retries),
+			zap.Error(err),
+		)
+		c.deadLetters.Add(req, err)
+		c.finish(req, TaskStats{Failures: 1, DeadLetters: 1})
+		return
+	}
+	c.Logger.Warn("robots.txt unavailable, defer request",
+		zap.String("url", req.Url),
+		zap.Int("attempts", req.Retries),
+		zap.Time("until", until),
+	)
+	c.stats.add(req.Task.Name, TaskStats{Failures: 1, Retries: 1})
+	c.scheduler.Release(req)
+	req.NotBefore = until
+	c.enqueue(req)
+}
+
+// DeadLetters 返回死信队列
+func (c *Crawler) DeadLetters() *DeadLetterQueue {
+	return c.deadLetters
+}
+
+// ErrCrawlerStopped 爬虫已完成或停止，不再接收新请求
+var ErrCrawlerStopped = errors.New("crawler stopped")
+
+// Reinject 将死信重新加入调度，重试次数清零
+// 需在Run之前或Run运行期间调用，爬虫已完成或停止后返回ErrCrawlerStopped，死信需在下次运行时注入
+func (c *Crawler) Reinject(letters ...DeadLetter) error {
+	if c.stopped.Load() {
+		return ErrCrawlerStopped
+	}
+	select {
+	case <-c.track.Idle():
+		return ErrCrawlerStopped
+	default:
+	}
+	reqs := make([]*collect.Request, 0, len(letters))
+	for _, letter := range letters {
+		letter.Req.Retries = 0
+		letter.Req.NotBefore = time.Time{}
+		reqs = append(reqs, letter.Req)
+	}
+	c.push(reqs...)
+	return nil
 }
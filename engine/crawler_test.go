@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fetchFunc func(ctx context.Context, req *collect.Request) (*collect.Response, error)
+
+func (f fetchFunc) Get(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+	return f(ctx, req)
+}
+
+// addTestTask 注册一个只有根请求的任务
+func addTestTask(name string, urls ...string) {
+	Store.Add(&collect.Task{
+		Options: collect.Options{Name: name},
+		Rule: collect.RuleTree{
+			Root: func() ([]*collect.Request, error) {
+				reqs := make([]*collect.Request, 0, len(urls))
+				for _, u := range urls {
+					reqs = append(reqs, &collect.Request{Url: u, Method: http.MethodGet, RuleName: "page"})
+				}
+				return reqs, nil
+			},
+			Trunk: map[string]*collect.Rule{
+				"page": {ParseFunc: func(*collect.CrawlerContext) (collect.ParseResult, error) {
+					return collect.ParseResult{}, nil
+				}},
+			},
+		},
+	})
+}
+
+func okResponse(req *collect.Request) *collect.Response {
+	u, _ := url.Parse(req.Url)
+	return &collect.Response{StatusCode: http.StatusOK, URL: u}
+}
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	addTestTask("test_shutdown_drain", "http://example.com/slow")
+	started := make(chan struct{})
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return okResponse(req), nil
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_shutdown_drain"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+		WithShutdownTimeout(time.Second),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	summary := c.Run(ctx)
+	assert.False(t, summary.Completed)
+	assert.Equal(t, int64(1), summary.Total.Requests)
+	assert.Equal(t, 0, summary.Pending)
+}
+
+func TestShutdownTimeoutKeepsPending(t *testing.T) {
+	addTestTask("test_shutdown_timeout", "http://example.com/hang")
+	started := make(chan struct{})
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_shutdown_timeout"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+	)
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+	store, err := NewFileQueueStore(path)
+	require.NoError(t, err)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule(WithQueueStore(store))),
+		WithShutdownTimeout(50*time.Millisecond),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	summary := c.Run(ctx)
+	assert.False(t, summary.Completed)
+	assert.Equal(t, 1, summary.Pending)
+	assert.Equal(t, int64(0), summary.Total.DeadLetters)
+	assert.Equal(t, 0, c.DeadLetters().Len())
+
+	// 被取消的请求保留在持久化存储中，下次运行时恢复
+	require.NoError(t, store.Close())
+	store, err = NewFileQueueStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	reqs, err := store.Load()
+	require.NoError(t, err)
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "http://example.com/hang", reqs[0].Url)
+}
+
+func TestRunWithoutRequests(t *testing.T) {
+	addTestTask("test_no_requests")
+	seeds := []*collect.Task{
+		collect.NewTask(collect.WithName("test_not_registered")),
+		collect.NewTask(collect.WithName("test_no_requests")),
+	}
+	c := NewCrawler(
+		WithTasks(seeds),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+	)
+
+	done := make(chan Summary)
+	go func() {
+		done <- c.Run(context.Background())
+	}()
+	select {
+	case summary := <-done:
+		assert.True(t, summary.Completed)
+		assert.Equal(t, 0, summary.Pending)
+	case <-time.After(5 * time.Second):
+		t.Fatal("crawler did not complete without requests")
+	}
+}
+
+func TestPushDropsVisitedAndTooDeep(t *testing.T) {
+	Store.Add(&collect.Task{
+		Options: collect.Options{Name: "test_push_filter"},
+		Rule: collect.RuleTree{
+			Root: func() ([]*collect.Request, error) {
+				return []*collect.Request{
+					{Url: "http://example.com/a", RuleName: "page"},
+					{Url: "http://example.com/a", RuleName: "page"},
+					{Url: "http://example.com/deep", RuleName: "page", Depth: 10},
+					{Url: "http://example.com/b", RuleName: "page"},
+				}, nil
+			},
+			Trunk: map[string]*collect.Rule{
+				"page": {ParseFunc: func(*collect.CrawlerContext) (collect.ParseResult, error) {
+					return collect.ParseResult{}, nil
+				}},
+			},
+		},
+	})
+	var fetched []string
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		fetched = append(fetched, req.Url)
+		return okResponse(req), nil
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_push_filter"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+		collect.WithWaitTime(0),
+	)
+	delay := 200 * time.Millisecond
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule(WithHostDelay(delay))),
+	)
+
+	start := time.Now()
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, []string{"http://example.com/a", "http://example.com/b"}, fetched)
+	assert.Equal(t, int64(2), summary.Total.Requests)
+	assert.Equal(t, int64(2), summary.Total.Dropped)
+	// 被丢弃的请求不占用站点的请求间隔
+	assert.Less(t, time.Since(start), 2*delay)
+}
+
+func TestPollBypassesVisited(t *testing.T) {
+	const page = "http://example.com/thread"
+	Store.Add(&collect.Task{
+		Options: collect.Options{Name: "test_poll"},
+		Rule: collect.RuleTree{
+			Root: func() ([]*collect.Request, error) {
+				return []*collect.Request{{Url: page, RuleName: "page"}}, nil
+			},
+			Trunk: map[string]*collect.Rule{
+				"page": {ParseFunc: func(ctx *collect.CrawlerContext) (collect.ParseResult, error) {
+					if ctx.Req.Poll {
+						return collect.ParseResult{}, nil
+					}
+					// 只设置了NotBefore的请求仍做重复访问检查
+					return collect.ParseResult{Requests: []*collect.Request{
+						(&collect.Request{Task: ctx.Req.Task, Url: page, RuleName: "page"}).Delay(10 * time.Millisecond),
+						(&collect.Request{Task: ctx.Req.Task, Url: page, RuleName: "page"}).PollAfter(10 * time.Millisecond),
+					}}, nil
+				}},
+			},
+		},
+	})
+	var fetched []bool
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		fetched = append(fetched, req.Poll)
+		return okResponse(req), nil
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_poll"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+		collect.WithWaitTime(0),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+	)
+
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, []bool{false, true}, fetched)
+	assert.Equal(t, int64(2), summary.Total.Requests)
+	assert.Equal(t, int64(1), summary.Total.Dropped)
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// DeadLetter 重试耗尽的失败请求
+type DeadLetter struct {
+	Req      *collect.Request
+	Err      string
+	Attempts int
+	Time     time.Time
+}
+
+type deadLetterRecord struct {
+	Request  RequestRecord `json:"request"`
+	Err      string        `json:"err"`
+	Attempts int           `json:"attempts"`
+	Time     time.Time     `json:"time"`
+}
+
+// DeadLetterQueue 死信队列
+// 保存重试耗尽的请求，可列出、导出为JSON Lines，并在之后重新注入爬虫
+type DeadLetterQueue struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func NewDeadLetterQueue() *DeadLetterQueue {
+	return &DeadLetterQueue{}
+}
+
+func (q *DeadLetterQueue) Add(req *collect.Request, err error) {
+	letter := DeadLetter{
+		Req:      req,
+		Attempts: req.Retries,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		letter.Err = err.Error()
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters = append(q.letters, letter)
+}
+
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.letters)
+}
+
+// List 返回当前死信的副本
+func (q *DeadLetterQueue) List() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	letters := make([]DeadLetter, len(q.letters))
+	copy(letters, q.letters)
+	return letters
+}
+
+// Drain 取出并清空全部死信
+func (q *DeadLetterQueue) Drain() []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	letters := q.letters
+	q.letters = nil
+	return letters
+}
+
+// Export 以JSON Lines格式导出死信，每行一个请求
+func (q *DeadLetterQueue) Export(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, letter := range q.List() {
+		rec := deadLetterRecord{
+			Request:  NewRequestRecord(letter.Req),
+			Err:      letter.Err,
+			Attempts: letter.Attempts,
+			Time:     letter.Time,
+		}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadDeadLetters 读取Export导出的死信，请求的任务需已注册到Store中
+func LoadDeadLetters(r io.Reader) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec deadLetterRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		req, err := rec.Request.Request()
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, DeadLetter{
+			Req:      req,
+			Err:      rec.Err,
+			Attempts: rec.Attempts,
+			Time:     rec.Time,
+		})
+	}
+
+	return letters, scanner.Err()
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDeadLetterReinject 重试耗尽的请求进入死信队列，导出后在下次运行时重新注入
+func TestDeadLetterReinject(t *testing.T) {
+	var roots int32
+	Store.Add(&collect.Task{
+		Options: collect.Options{Name: "test_dead_letter"},
+		Rule: collect.RuleTree{
+			// 只有第一次运行产生根请求，第二次运行只处理重新注入的死信
+			Root: func() ([]*collect.Request, error) {
+				if atomic.AddInt32(&roots, 1) > 1 {
+					return nil, nil
+				}
+				req := &collect.Request{Url: "http://example.com/fail", Method: http.MethodGet, RuleName: "page", TmpData: &collect.Tmp{}}
+				req.TmpData.Set("page", "1")
+				return []*collect.Request{req}, nil
+			},
+			Trunk: map[string]*collect.Rule{
+				"page": {ParseFunc: func(*collect.CrawlerContext) (collect.ParseResult, error) {
+					return collect.ParseResult{}, nil
+				}},
+			},
+		},
+	})
+	failing := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		return nil, errors.New("connection reset")
+	})
+	policy := collect.RetryPolicy{MaxAttempts: 2, BaseDelay: 1}
+	c := NewCrawler(
+		WithTasks([]*collect.Task{collect.NewTask(
+			collect.WithName("test_dead_letter"),
+			collect.WithFetcher(failing),
+			collect.WithIgnoreRobots(true),
+			collect.WithWaitTime(0),
+		)}),
+		WithScheduler(NewSchedule()),
+		WithWorkCount(1),
+		WithRetryPolicy(policy),
+	)
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(2), summary.Total.Failures)
+	assert.Equal(t, int64(1), summary.Total.Retries)
+	assert.Equal(t, int64(1), summary.Total.DeadLetters)
+	require.Equal(t, 1, c.DeadLetters().Len())
+	// 运行结束后不能再注入
+	assert.ErrorIs(t, c.Reinject(c.DeadLetters().List()...), ErrCrawlerStopped)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DeadLetters().Export(&buf))
+	letters, err := LoadDeadLetters(&buf)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "http://example.com/fail", letters[0].Req.Url)
+	assert.Equal(t, 2, letters[0].Attempts)
+	assert.Equal(t, "connection reset", letters[0].Err)
+	assert.Equal(t, "1", letters[0].Req.TmpData.Get("page"))
+
+	var fetched []string
+	ok := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		fetched = append(fetched, req.Url)
+		return okResponse(req), nil
+	})
+	c = NewCrawler(
+		WithTasks([]*collect.Task{collect.NewTask(
+			collect.WithName("test_dead_letter"),
+			collect.WithFetcher(ok),
+			collect.WithIgnoreRobots(true),
+			collect.WithWaitTime(0),
+		)}),
+		WithScheduler(NewSchedule()),
+		WithWorkCount(1),
+		WithRetryPolicy(policy),
+	)
+	require.NoError(t, c.Reinject(letters...))
+	summary = c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(1), summary.Total.Requests)
+	assert.Equal(t, []string{"http://example.com/fail"}, fetched)
+	assert.Equal(t, 0, c.DeadLetters().Len())
+}
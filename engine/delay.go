@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// delayQueue 未到NotBefore时间的请求，按NotBefore从早到晚排序
+type delayQueue []*collect.Request
+
+func (q delayQueue) Len() int {
+	return len(q)
+}
+
+func (q delayQueue) Less(i, j int) bool {
+	return q[i].NotBefore.Before(q[j].NotBefore)
+}
+
+func (q delayQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *delayQueue) Push(x any) {
+	*q = append(*q, x.(*collect.Request))
+}
+
+func (q *delayQueue) Pop() any {
+	old := *q
+	n := len(old)
+	req := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return req
+}
+
+// due 取出所有到期的请求，并返回距离下一个请求到期的时长，没有延迟请求时返回0
+func (q *delayQueue) due(now time.Time) ([]*collect.Request, time.Duration) {
+	var reqs []*collect.Request
+	for q.Len() > 0 {
+		next := (*q)[0]
+		if d := next.NotBefore.Sub(now); d > 0 {
+			return reqs, d
+		}
+		reqs = append(reqs, heap.Pop(q).(*collect.Request))
+	}
+	return reqs, 0
+}
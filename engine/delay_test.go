@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"container/heap"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelayQueueDue(t *testing.T) {
+	now := time.Now()
+	var q delayQueue
+	for _, r := range []*collect.Request{
+		{Url: "c", NotBefore: now.Add(3 * time.Second)},
+		{Url: "a", NotBefore: now.Add(-time.Second)},
+		{Url: "b", NotBefore: now},
+	} {
+		heap.Push(&q, r)
+	}
+
+	reqs, wait := q.due(now)
+	require.Len(t, reqs, 2)
+	assert.Equal(t, "a", reqs[0].Url)
+	assert.Equal(t, "b", reqs[1].Url)
+	assert.Equal(t, 3*time.Second, wait)
+
+	reqs, wait = q.due(now.Add(3 * time.Second))
+	require.Len(t, reqs, 1)
+	assert.Equal(t, "c", reqs[0].Url)
+	assert.Equal(t, time.Duration(0), wait)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestScheduleDelayedRequest(t *testing.T) {
+	task := collect.NewTask(collect.WithName("test_delay"))
+	s := NewSchedule()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Schedule(ctx)
+
+	delay := 100 * time.Millisecond
+	start := time.Now()
+	s.Push(
+		(&collect.Request{Task: task, Url: "http://a.example.com/delayed"}).Delay(delay),
+		&collect.Request{Task: task, Url: "http://b.example.com/now"},
+	)
+
+	r, err := s.Pull(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "http://b.example.com/now", r.Url)
+	r, err = s.Pull(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "http://a.example.com/delayed", r.Url)
+	assert.GreaterOrEqual(t, time.Since(start), delay)
+}
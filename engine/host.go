@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"math/rand"
+	"net/url"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// hostQueue 单个站点的待调度请求
+type hostQueue struct {
+	host  string
+	queue *priorityQueue
+	// active 已分配给worker尚未处理完成的请求数
+	active int
+	// nextAt 下一个请求最早的分配时间
+	nextAt time.Time
+}
+
+func requestHost(req *collect.Request) string {
+	u, err := url.Parse(req.Url)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// pushHost 请求加入对应站点的队列
+func (s *Schedule) pushHost(req *collect.Request) {
+	host := requestHost(req)
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostQueue{
+			host:  host,
+			queue: newPriorityQueue(s.aging, s.start, s.strategy),
+		}
+		s.hosts[host] = h
+		s.ring = append(s.ring, host)
+	}
+	h.queue.Push(req)
+	s.queued++
+}
+
+// popHost 在可分配的站点中选出队首有效优先级最高的请求，优先级相同时按站点轮询
+// 没有可分配的请求时返回最近一个站点可分配的等待时长，为0表示需要等待新请求或请求处理完成
+func (s *Schedule) popHost(now time.Time) (*collect.Request, time.Duration) {
+	var (
+		best     *hostQueue
+		bestIdx  int
+		wait     time.Duration
+		n        = len(s.ring)
+		maxConns = s.maxHostConns
+	)
+	for i := 0; i < n; i++ {
+		idx := (s.next + i) % n
+		h := s.hosts[s.ring[idx]]
+		head := h.queue.peek()
+		if head == nil {
+			continue
+		}
+		if maxConns > 0 && h.active >= maxConns {
+			continue
+		}
+		if until := s.coolDownUntil(h.host); until.After(h.nextAt) {
+			h.nextAt = until
+		}
+		if d := h.nextAt.Sub(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		if best == nil || higher(head, best.queue.peek()) {
+			best = h
+			bestIdx = idx
+		}
+	}
+	if best == nil {
+		s.cleanHosts(now)
+		return nil, wait
+	}
+	req := best.queue.Pop()
+	s.queued--
+	best.active++
+	best.nextAt = now.Add(s.hostDelay(best.host) + taskWait(req))
+	s.next = bestIdx + 1
+
+	return req, 0
+}
+
+// taskWait 请求所属任务WaitTime内的随机等待时长，在站点延迟之外额外等待
+func taskWait(req *collect.Request) time.Duration {
+	if req.Task == nil || req.Task.WaitTime <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(req.Task.WaitTime * int64(time.Second)))
+}
+
+// ackHost 请求处理完成，释放站点的连接数
+func (s *Schedule) ackHost(req *collect.Request) {
+	if h, ok := s.hosts[requestHost(req)]; ok && h.active > 0 {
+		h.active--
+	}
+}
+
+// cleanHosts 移除没有待调度请求且已过等待期的站点
+func (s *Schedule) cleanHosts(now time.Time) {
+	ring := s.ring[:0]
+	for _, host := range s.ring {
+		h := s.hosts[host]
+		if h.queue.Len() == 0 && h.active == 0 && !now.Before(h.nextAt) {
+			delete(s.hosts, host)
+			continue
+		}
+		ring = append(ring, host)
+	}
+	s.ring = ring
+	if len(s.ring) > 0 {
+		s.next %= len(s.ring)
+	} else {
+		s.next = 0
+	}
+}
+
+// SetHostDelay 设置站点两次请求之间的最小间隔，覆盖WithHostDelay的默认值
+func (s *Schedule) SetHostDelay(host string, delay time.Duration) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	s.hostDelays[host] = delay
+}
+
+// SetMinHostDelay 站点请求间隔小于delay时设置为delay，用于遵守robots.txt的Crawl-delay
+func (s *Schedule) SetMinHostDelay(host string, delay time.Duration) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	current, ok := s.hostDelays[host]
+	if !ok {
+		current = s.defaultHostDelay
+	}
+	if delay > current {
+		s.hostDelays[host] = delay
+	}
+}
+
+// CoolDown 站点在d时长内不再分配请求，用于被封禁后暂停访问
+func (s *Schedule) CoolDown(host string, d time.Duration) {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(s.coolDowns[host]) {
+		s.coolDowns[host] = until
+	}
+}
+
+// coolDownUntil 返回站点冷却结束的时间，已结束的冷却被移除
+func (s *Schedule) coolDownUntil(host string) time.Time {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	until, ok := s.coolDowns[host]
+	if ok && !until.After(time.Now()) {
+		delete(s.coolDowns, host)
+	}
+	return until
+}
+
+func (s *Schedule) hostDelay(host string) time.Duration {
+	s.hostMu.Lock()
+	defer s.hostMu.Unlock()
+	if d, ok := s.hostDelays[host]; ok {
+		return d
+	}
+	return s.defaultHostDelay
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hostReq(url string) *collect.Request {
+	return &collect.Request{Url: url, RuleName: "page"}
+}
+
+func TestPopHostRoundRobin(t *testing.T) {
+	s := NewSchedule()
+	for _, u := range []string{"http://a.com/1", "http://a.com/2", "http://b.com/1", "http://b.com/2"} {
+		s.pushHost(hostReq(u))
+	}
+	now := time.Now()
+	var got []string
+	for i := 0; i < 4; i++ {
+		req, wait := s.popHost(now)
+		require.NotNil(t, req)
+		assert.Zero(t, wait)
+		got = append(got, req.Url)
+	}
+	assert.Equal(t, []string{"http://a.com/1", "http://b.com/1", "http://a.com/2", "http://b.com/2"}, got)
+	assert.Zero(t, s.queued)
+}
+
+func TestPopHostDelay(t *testing.T) {
+	s := NewSchedule(WithHostDelay(time.Second))
+	s.pushHost(hostReq("http://a.com/1"))
+	s.pushHost(hostReq("http://a.com/2"))
+	s.pushHost(hostReq("http://b.com/1"))
+	now := time.Now()
+
+	req, _ := s.popHost(now)
+	assert.Equal(t, "http://a.com/1", req.Url)
+	// a.com未到间隔时不阻塞其他站点
+	req, _ = s.popHost(now)
+	assert.Equal(t, "http://b.com/1", req.Url)
+	req, wait := s.popHost(now.Add(300 * time.Millisecond))
+	assert.Nil(t, req)
+	assert.Equal(t, 700*time.Millisecond, wait)
+
+	req, _ = s.popHost(now.Add(time.Second))
+	require.NotNil(t, req)
+	assert.Equal(t, "http://a.com/2", req.Url)
+}
+
+func TestPopHostMaxConns(t *testing.T) {
+	s := NewSchedule(WithMaxHostConns(1))
+	a1 := hostReq("http://a.com/1")
+	s.pushHost(a1)
+	s.pushHost(hostReq("http://a.com/2"))
+	now := time.Now()
+
+	req, _ := s.popHost(now)
+	assert.Same(t, a1, req)
+	// 连接数已满，等待请求完成而不是定时唤醒
+	req, wait := s.popHost(now)
+	assert.Nil(t, req)
+	assert.Zero(t, wait)
+
+	s.ackHost(a1)
+	req, _ = s.popHost(now)
+	require.NotNil(t, req)
+	assert.Equal(t, "http://a.com/2", req.Url)
+}
+
+func TestCleanHosts(t *testing.T) {
+	s := NewSchedule(WithHostDelay(time.Second))
+	a1 := hostReq("http://a.com/1")
+	s.pushHost(a1)
+	now := time.Now()
+	req, _ := s.popHost(now)
+	require.Same(t, a1, req)
+
+	// 请求未完成或间隔未到时保留站点
+	req, _ = s.popHost(now.Add(2 * time.Second))
+	assert.Nil(t, req)
+	assert.Contains(t, s.hosts, "a.com")
+	s.ackHost(a1)
+	s.popHost(now)
+	assert.Contains(t, s.hosts, "a.com")
+
+	s.popHost(now.Add(time.Second))
+	assert.Empty(t, s.hosts)
+	assert.Empty(t, s.ring)
+	assert.Zero(t, s.next)
+}
+
+func TestSetMinHostDelay(t *testing.T) {
+	s := NewSchedule(WithHostDelay(2 * time.Second))
+	s.SetMinHostDelay("a.com", time.Second)
+	assert.Equal(t, 2*time.Second, s.hostDelay("a.com"))
+	s.SetMinHostDelay("a.com", 3*time.Second)
+	assert.Equal(t, 3*time.Second, s.hostDelay("a.com"))
+	s.SetMinHostDelay("a.com", time.Second)
+	assert.Equal(t, 3*time.Second, s.hostDelay("a.com"))
+	assert.Equal(t, 2*time.Second, s.hostDelay("b.com"))
+
+	// SetHostDelay直接覆盖
+	s.SetHostDelay("a.com", time.Second)
+	assert.Equal(t, time.Second, s.hostDelay("a.com"))
+}
+
+func TestCoolDown(t *testing.T) {
+	s := NewSchedule()
+	s.pushHost(hostReq("http://a.com/1"))
+	s.pushHost(hostReq("http://b.com/1"))
+	s.CoolDown("a.com", time.Hour)
+	// 较短的冷却不覆盖较长的冷却
+	s.CoolDown("a.com", time.Second)
+	now := time.Now()
+
+	req, _ := s.popHost(now)
+	require.NotNil(t, req)
+	assert.Equal(t, "http://b.com/1", req.Url)
+	req, wait := s.popHost(now)
+	assert.Nil(t, req)
+	assert.Greater(t, wait, 59*time.Minute)
+
+	// 冷却结束后恢复分配
+	req, _ = s.popHost(now.Add(time.Hour + time.Second))
+	require.NotNil(t, req)
+	assert.Equal(t, "http://a.com/1", req.Url)
+}
@@ -0,0 +1,49 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/limiter"
+)
+
+// feedback 将请求结果反馈给任务的限流器，用于自适应调整速率
+func (c *Crawler) feedback(r *collect.Request, resp *collect.Response, verdict collect.Verdict, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	f := limiter.Feedback{
+		Banned: verdict == collect.Ban,
+		Err:    err,
+	}
+	if resp != nil {
+		f.StatusCode = resp.StatusCode
+		f.Latency = resp.Elapsed
+		f.Err = nil
+		if resp.Header != nil {
+			f.RetryAfter = limiter.RetryAfter(resp.Header.Get("Retry-After"))
+		}
+	}
+	if fl, ok := r.Limiter().(limiter.FeedbackLimiter); ok {
+		fl.Feedback(f)
+	}
+}
+
+// limitStats 各任务限流器的统计，规则的限流器以"任务/规则"为键
+func (c *Crawler) limitStats() map[string]limiter.Stats {
+	stats := map[string]limiter.Stats{}
+	for _, seed := range c.Seeds {
+		task := Store.hash[seed.Name]
+		if task == nil {
+			continue
+		}
+		if task.Limit != nil {
+			stats[task.Name] = task.Limit.Stats()
+		}
+		for rule, l := range task.RuleLimits {
+			stats[task.Name+"/"+rule] = l.Stats()
+		}
+	}
+	return stats
+}
@@ -1,7 +1,10 @@
 package engine
 
 import (
+	"time"
+
 	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/robots"
 	"go.uber.org/zap"
 )
 
@@ -13,10 +16,22 @@ type options struct {
 	Logger    *zap.Logger
 	Seeds     []*collect.Task
 	scheduler Scheduler
+	// RetryPolicy 任务未配置重试策略时使用
+	RetryPolicy collect.RetryPolicy
+	// ShutdownTimeout 停止时等待进行中请求的最长时间
+	ShutdownTimeout time.Duration
+	// CheckpointPath 已访问集合的检查点文件，为空时不保存
+	CheckpointPath     string
+	CheckpointInterval time.Duration
+	// robots robots.txt缓存，为空时使用默认配置创建
+	robots *robots.Cache
 }
 
 var defaultOptions = options{
-	Logger: zap.NewNop(),
+	Logger:             zap.NewNop(),
+	RetryPolicy:        collect.DefaultRetryPolicy,
+	ShutdownTimeout:    30 * time.Second,
+	CheckpointInterval: time.Minute,
 }
 
 func WithLogger(logger *zap.Logger) Option {
@@ -48,3 +63,32 @@ func WithScheduler(scheduler Scheduler) Option {
 		opt.scheduler = scheduler
 	}
 }
+
+func WithRetryPolicy(policy collect.RetryPolicy) Option {
+	return func(opt *options) {
+		opt.RetryPolicy = policy
+	}
+}
+
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(opt *options) {
+		opt.ShutdownTimeout = timeout
+	}
+}
+
+// WithCheckpoint 定期将已访问集合保存到path，启动时从path恢复
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(opt *options) {
+		opt.CheckpointPath = path
+		if interval > 0 {
+			opt.CheckpointInterval = interval
+		}
+	}
+}
+
+// WithRobots 设置robots.txt缓存，可指定匹配规则使用的User-agent
+func WithRobots(cache *robots.Cache) Option {
+	return func(opt *options) {
+		opt.robots = cache
+	}
+}
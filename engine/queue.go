@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+type queueItem struct {
+	req *collect.Request
+	// seq 入队序号，优先级相同时先进先出
+	seq uint64
+	// key 有效优先级，越大越先出队
+	key float64
+	// score 爬取策略的得分，有效优先级相同时比较
+	score float64
+}
+
+// priorityQueue 基于堆的请求优先队列
+// Request.Priority越大越先出队，优先级相同时由爬取策略决定顺序
+// aging大于0时，请求每等待aging时长有效优先级提高1，避免低优先级请求一直得不到调度
+type priorityQueue struct {
+	items    []*queueItem
+	seq      uint64
+	aging    time.Duration
+	start    time.Time
+	strategy Strategy
+}
+
+// newPriorityQueue start为计算等待时长的起点，需要比较有效优先级的多个队列应使用相同的start
+func newPriorityQueue(aging time.Duration, start time.Time, strategy Strategy) *priorityQueue {
+	return &priorityQueue{
+		aging:    aging,
+		start:    start,
+		strategy: strategy,
+	}
+}
+
+// Push 有效优先级 = Priority + 等待时长/aging
+// 出队时比较的是同一时刻的有效优先级，因此可以在入队时用 Priority - 入队时间/aging 作为固定的排序键
+func (q *priorityQueue) Push(req *collect.Request) {
+	q.seq++
+	item := &queueItem{
+		req: req,
+		seq: q.seq,
+		key: float64(req.Priority),
+	}
+	if q.aging > 0 {
+		item.key -= float64(time.Since(q.start)) / float64(q.aging)
+	}
+	if q.strategy.score != nil {
+		item.score = q.strategy.score(req)
+	}
+	heap.Push((*itemHeap)(q), item)
+}
+
+func (q *priorityQueue) Pop() *collect.Request {
+	if len(q.items) == 0 {
+		return nil
+	}
+	item := heap.Pop((*itemHeap)(q)).(*queueItem)
+	q.shrink()
+	return item.req
+}
+
+// peek 返回队首元素，不出队
+func (q *priorityQueue) peek() *queueItem {
+	if len(q.items) == 0 {
+		return nil
+	}
+	return q.items[0]
+}
+
+func (q *priorityQueue) Len() int {
+	return len(q.items)
+}
+
+// shrink 队列大量出队后释放底层数组
+func (q *priorityQueue) shrink() {
+	if c := cap(q.items); c > 64 && len(q.items) < c/4 {
+		items := make([]*queueItem, len(q.items), c/2)
+		copy(items, q.items)
+		q.items = items
+	}
+}
+
+// higher 比较不同队列的队首元素，入队序号不可比较，只比较有效优先级与策略得分
+func higher(a, b *queueItem) bool {
+	if a.key != b.key {
+		return a.key > b.key
+	}
+	return a.score > b.score
+}
+
+// itemHeap 实现heap.Interface
+type itemHeap priorityQueue
+
+func (h *itemHeap) Len() int {
+	return len(h.items)
+}
+
+func (h *itemHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.key != b.key {
+		return a.key > b.key
+	}
+	return h.strategy.less(a, b)
+}
+
+func (h *itemHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+}
+
+func (h *itemHeap) Push(x any) {
+	h.items = append(h.items, x.(*queueItem))
+}
+
+func (h *itemHeap) Pop() any {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items[n-1] = nil
+	h.items = h.items[:n-1]
+	return item
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// QueueStore 调度队列的持久化存储
+// 请求进入调度器时Add，处理完成后Remove，重启后通过Load恢复未完成的请求
+type QueueStore interface {
+	// Load 返回上次运行未完成的请求
+	Load() ([]*collect.Request, error)
+	Add(*collect.Request) error
+	Remove(*collect.Request) error
+	Close() error
+}
+
+const (
+	journalAdd    = "add"
+	journalRemove = "remove"
+)
+
+type journalEntry struct {
+	Op  string         `json:"op"`
+	ID  uint64         `json:"id"`
+	Req *RequestRecord `json:"req,omitempty"`
+}
+
+// FileQueueStore 基于本地文件的队列存储
+// 以JSON Lines格式追加记录每次Add和Remove，打开时回放日志并压缩
+// 请求的编号记录在Request.QueueID中，存储不持有请求，溢出到磁盘后读回的请求仍对应原来的记录
+type FileQueueStore struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	enc      *json.Encoder
+	nextID   uint64
+	restored []journalEntry
+}
+
+func NewFileQueueStore(path string) (*FileQueueStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileQueueStore{
+		path: path,
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	s.enc = json.NewEncoder(f)
+
+	return s, nil
+}
+
+// compact 回放日志，只保留未完成的请求
+func (s *FileQueueStore) compact() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	live := map[uint64]journalEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// 最后一行可能因进程退出写入不完整
+			continue
+		}
+		switch entry.Op {
+		case journalAdd:
+			live[entry.ID] = entry
+		case journalRemove:
+			delete(live, entry.ID)
+		}
+		if entry.ID > s.nextID {
+			s.nextID = entry.ID
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, entry := range live {
+		s.restored = append(s.restored, entry)
+	}
+	sort.Slice(s.restored, func(i, j int) bool {
+		return s.restored[i].ID < s.restored[j].ID
+	})
+
+	tmp := s.path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	for _, entry := range s.restored {
+		if err := enc.Encode(entry); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// Load 返回上次运行未完成的请求，请求的任务需已注册到Store中
+func (s *FileQueueStore) Load() ([]*collect.Request, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := make([]*collect.Request, 0, len(s.restored))
+	for _, entry := range s.restored {
+		req, err := entry.Req.Request()
+		if err != nil {
+			return nil, err
+		}
+		req.QueueID = entry.ID
+		reqs = append(reqs, req)
+	}
+	s.restored = nil
+
+	return reqs, nil
+}
+
+// Add 已记录的请求（例如重试）以原来的ID写入最新状态，回放时覆盖之前的记录
+func (s *FileQueueStore) Add(req *collect.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.QueueID == 0 {
+		s.nextID++
+		req.QueueID = s.nextID
+	}
+	rec := NewRequestRecord(req)
+	return s.enc.Encode(journalEntry{Op: journalAdd, ID: req.QueueID, Req: &rec})
+}
+
+func (s *FileQueueStore) Remove(req *collect.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := req.QueueID
+	if id == 0 {
+		return nil
+	}
+	req.QueueID = 0
+	return s.enc.Encode(journalEntry{Op: journalRemove, ID: id})
+}
+
+func (s *FileQueueStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func TestFileQueueStoreRestore(t *testing.T) {
+	addTestTask("test_queue_store")
+	task := Store.hash["test_queue_store"]
+	path := filepath.Join(t.TempDir(), "queue.jsonl")
+
+	s, err := NewFileQueueStore(path)
+	require.NoError(t, err)
+	a := &collect.Request{Task: task, Url: "a", RuleName: "page"}
+	b := &collect.Request{Task: task, Url: "b", RuleName: "page"}
+	c := &collect.Request{Task: task, Url: "c", RuleName: "page"}
+	for _, req := range []*collect.Request{a, b, c} {
+		require.NoError(t, s.Add(req))
+	}
+	require.NoError(t, s.Remove(b))
+	// 重试的请求写入最新的状态
+	a.Retries = 2
+	require.NoError(t, s.Add(a))
+	require.NoError(t, s.Close())
+	assert.Equal(t, 5, countLines(t, path))
+
+	s, err = NewFileQueueStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 2, countLines(t, path))
+	reqs, err := s.Load()
+	require.NoError(t, err)
+	require.Len(t, reqs, 2)
+	assert.Equal(t, "a", reqs[0].Url)
+	assert.Equal(t, 2, reqs[0].Retries)
+	assert.Equal(t, "c", reqs[1].Url)
+	assert.Same(t, task, reqs[0].Task)
+
+	// 恢复的请求完成后不再保留
+	require.NoError(t, s.Remove(reqs[0]))
+	require.NoError(t, s.Remove(reqs[1]))
+	s2, err := NewFileQueueStore(path)
+	require.NoError(t, err)
+	defer s2.Close()
+	reqs, err = s2.Load()
+	require.NoError(t, err)
+	assert.Empty(t, reqs)
+}
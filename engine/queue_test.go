@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		reqs     []*collect.Request
+		wants    []string
+	}{
+		{
+			name:  "empty",
+			reqs:  nil,
+			wants: nil,
+		},
+		{
+			name: "priority",
+			reqs: []*collect.Request{
+				{Url: "a", Priority: 0},
+				{Url: "b", Priority: 5},
+				{Url: "c", Priority: -1},
+				{Url: "d", Priority: 2},
+			},
+			wants: []string{"b", "d", "a", "c"},
+		},
+		{
+			name: "fifo on same priority",
+			reqs: []*collect.Request{
+				{Url: "a", Priority: 1},
+				{Url: "b", Priority: 0},
+				{Url: "c", Priority: 1},
+				{Url: "d", Priority: 0},
+				{Url: "e", Priority: 1},
+			},
+			wants: []string{"a", "c", "e", "b", "d"},
+		},
+		{
+			name:     "breadth first",
+			strategy: BreadthFirst,
+			reqs: []*collect.Request{
+				{Url: "a", Depth: 2},
+				{Url: "b", Depth: 1},
+				{Url: "c", Depth: 2},
+				{Url: "d", Depth: 0},
+			},
+			wants: []string{"d", "b", "a", "c"},
+		},
+		{
+			name:     "depth first",
+			strategy: DepthFirst,
+			reqs: []*collect.Request{
+				{Url: "a", Depth: 1},
+				{Url: "b", Depth: 2},
+				{Url: "c", Depth: 2},
+				{Url: "d", Depth: 0, Priority: 1},
+			},
+			wants: []string{"d", "c", "b", "a"},
+		},
+		{
+			name: "best first",
+			strategy: BestFirst(func(r *collect.Request) float64 {
+				if r.RuleName == "detail" {
+					return 1
+				}
+				return 0
+			}),
+			reqs: []*collect.Request{
+				{Url: "a", RuleName: "list"},
+				{Url: "b", RuleName: "detail"},
+				{Url: "c", RuleName: "list"},
+				{Url: "d", RuleName: "detail"},
+			},
+			wants: []string{"b", "d", "a", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newPriorityQueue(0, time.Now(), tt.strategy)
+			for _, req := range tt.reqs {
+				q.Push(req)
+			}
+			var got []string
+			for q.Len() > 0 {
+				got = append(got, q.Pop().Url)
+			}
+			assert.Equal(t, tt.wants, got)
+			assert.Nil(t, q.Pop())
+		})
+	}
+}
+
+func TestPriorityQueueAging(t *testing.T) {
+	q := newPriorityQueue(time.Millisecond, time.Now(), Strategy{})
+	q.Push(&collect.Request{Url: "low", Priority: 0})
+	time.Sleep(20 * time.Millisecond)
+	q.Push(&collect.Request{Url: "high", Priority: 5})
+	// low已等待超过5个aging周期，有效优先级高于high
+	assert.Equal(t, "low", q.Pop().Url)
+	assert.Equal(t, "high", q.Pop().Url)
+}
+
+func TestPriorityQueueShrink(t *testing.T) {
+	q := newPriorityQueue(0, time.Now(), Strategy{})
+	for i := 0; i < 1000; i++ {
+		q.Push(&collect.Request{Priority: i % 7})
+	}
+	for i := 0; i < 990; i++ {
+		q.Pop()
+	}
+	assert.Equal(t, 10, q.Len())
+	assert.Less(t, cap(q.items), 1000)
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// RequestRecord 请求的可序列化形式，用于导出与恢复请求
+// 任务通过名称关联，恢复时从全局Store中查找
+type RequestRecord struct {
+	Task      string        `json:"task"`
+	Url       string        `json:"url"`
+	Method    string        `json:"method"`
+	Header    http.Header   `json:"header,omitempty"`
+	Body      []byte        `json:"body,omitempty"`
+	Timeout   time.Duration `json:"timeout,omitempty"`
+	RuleName  string        `json:"rule_name"`
+	Depth     int           `json:"depth"`
+	Priority  int           `json:"priority"`
+	Retries   int           `json:"retries"`
+	NotBefore time.Time     `json:"not_before"`
+	Poll      bool          `json:"poll,omitempty"`
+	TmpData   *collect.Tmp  `json:"tmp_data,omitempty"`
+	// SessionKey 恢复后的请求使用原来的会话与代理
+	SessionKey string `json:"session_key,omitempty"`
+}
+
+func NewRequestRecord(r *collect.Request) RequestRecord {
+	rec := RequestRecord{
+		Url:        r.Url,
+		Method:     r.Method,
+		Header:     r.Header,
+		Body:       r.Body,
+		Timeout:    r.Timeout,
+		RuleName:   r.RuleName,
+		Depth:      r.Depth,
+		Priority:   r.Priority,
+		Retries:    r.Retries,
+		TmpData:    r.TmpData,
+		NotBefore:  r.NotBefore,
+		Poll:       r.Poll,
+		SessionKey: r.SessionKey,
+	}
+	if r.Task != nil {
+		rec.Task = r.Task.Name
+	}
+
+	return rec
+}
+
+// Request 还原请求
+func (rec RequestRecord) Request() (*collect.Request, error) {
+	task := Store.hash[rec.Task]
+	if task == nil {
+		return nil, fmt.Errorf("task not found:%s", rec.Task)
+	}
+
+	return &collect.Request{
+		Task:       task,
+		Url:        rec.Url,
+		Method:     rec.Method,
+		Header:     rec.Header,
+		Body:       rec.Body,
+		Timeout:    rec.Timeout,
+		RuleName:   rec.RuleName,
+		Depth:      rec.Depth,
+		Priority:   rec.Priority,
+		Retries:    rec.Retries,
+		TmpData:    rec.TmpData,
+		NotBefore:  rec.NotBefore,
+		Poll:       rec.Poll,
+		SessionKey: rec.SessionKey,
+	}, nil
+}
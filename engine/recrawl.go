@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"context"
+	"crypto/md5"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/cron"
+	"go.uber.org/zap"
+)
+
+// recrawl 按任务的cron计划重新生成根请求并加入调度
+// 根请求作为轮询请求不受去重限制，由根请求产生的请求是否重复爬取仍由任务的Reload决定
+// 存在定时任务时爬虫不会自行结束，需要取消ctx停止
+func (c *Crawler) recrawl(ctx context.Context, task *collect.Task, schedule cron.Schedule) {
+	c.track.Add(1)
+	c.pushing.Add(1)
+	go func() {
+		defer c.pushing.Done()
+		defer c.track.Done()
+		for {
+			next := schedule.Next(time.Now())
+			if next.IsZero() {
+				c.Logger.Warn("task cron has no next time", zap.String("task", task.Name))
+				return
+			}
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			reqs, err := task.Rule.Root()
+			if err != nil {
+				c.Logger.Error("task rule root err:", zap.String("task", task.Name), zap.Error(err))
+				continue
+			}
+			for _, req := range reqs {
+				req.Task = task
+				req.NotBefore = next
+				req.Poll = true
+			}
+			c.Logger.Info("recrawl task", zap.String("task", task.Name), zap.Int("count", len(reqs)))
+			c.push(reqs...)
+		}
+	}()
+}
+
+// changed 记录页面内容摘要，返回内容与上次获取时相比是否有变化
+func (c *Crawler) changed(r *collect.Request, body []byte) bool {
+	sum := md5.Sum(body)
+	unique := r.Unique()
+	c.digestLock.Lock()
+	defer c.digestLock.Unlock()
+	if prev, ok := c.digests[unique]; ok && prev == sum {
+		return false
+	}
+	c.digests[unique] = sum
+
+	return true
+}
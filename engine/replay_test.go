@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/collector"
+	"github.com/awaketai/crawler/limiter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+type memStorage struct {
+	cells []*collector.DataCell
+}
+
+func (s *memStorage) Save(cells ...*collector.DataCell) error {
+	s.cells = append(s.cells, cells...)
+	return nil
+}
+
+func (s *memStorage) Flush() error {
+	return nil
+}
+
+// TestReplayDoubanBook 回放录制的豆瓣读书页面，端到端运行douban_book_list任务
+func TestReplayDoubanBook(t *testing.T) {
+	fetcher, err := collect.NewReplayFetcher("testdata/douban_book.jsonl")
+	require.NoError(t, err)
+	storage := &memStorage{}
+	seed := collect.NewTask(
+		collect.WithName("douban_book_list"),
+		collect.WithFetcher(fetcher),
+		collect.WithStorage(storage),
+		collect.WithWaitTime(0),
+	)
+	seed.Limit = limiter.NewLimiter(rate.Inf, 1)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(2),
+		WithScheduler(NewSchedule()),
+	)
+
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(7), summary.Total.Requests)
+	assert.Equal(t, int64(0), summary.Total.Failures)
+	require.Len(t, storage.cells, 3)
+	book := storage.cells[0].Data["Data"].(map[string]any)
+	assert.NotEmpty(t, book["书名"])
+	assert.NotEmpty(t, book["作者"])
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/robots"
+	"go.uber.org/zap"
+)
+
+// robotsTimeout 获取robots.txt的超时时间
+const robotsTimeout = 10 * time.Second
+
+// hostDelayer 支持设置站点请求间隔的调度器
+type hostDelayer interface {
+	SetMinHostDelay(host string, delay time.Duration)
+}
+
+// allowed 过滤robots.txt禁止访问的请求，robots.txt暂时无法获取的请求推迟调度
+func (c *Crawler) allowed(reqs []*collect.Request) []*collect.Request {
+	kept := make([]*collect.Request, 0, len(reqs))
+	for _, req := range reqs {
+		ok, until, _ := c.checkRobots(req)
+		if !until.IsZero() {
+			c.Logger.Warn("robots.txt unavailable, defer request", zap.String("url", req.Url), zap.Time("until", until))
+			req.NotBefore = until
+			kept = append(kept, req)
+			continue
+		}
+		if !ok {
+			c.Logger.Warn("disallowed by robots.txt", zap.String("url", req.Url))
+			c.stats.add(req.Task.Name, TaskStats{Dropped: 1})
+			continue
+		}
+		kept = append(kept, req)
+	}
+
+	return kept
+}
+
+// checkRobots 返回robots.txt是否允许访问请求，robots.txt暂时无法获取时返回可以重新检查的时间与原因
+// robots.txt通过任务的Fetcher获取，站点的Crawl-delay作为调度器中该站点的最小请求间隔
+func (c *Crawler) checkRobots(req *collect.Request) (bool, time.Time, error) {
+	if req.Task.IgnoreRobots || (req.Test && len(req.TestBody) > 0) {
+		return true, time.Time{}, nil
+	}
+	u, err := url.Parse(req.Url)
+	if err != nil || u.Host == "" {
+		return true, time.Time{}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), robotsTimeout)
+	defer cancel()
+	if req.Task.Fetcher != nil {
+		ctx = robots.NewContext(ctx, robotsFetch(req.Task))
+	}
+	ok, err := c.robots.Allowed(ctx, u)
+	if err != nil {
+		var unavailable *robots.UnavailableError
+		if errors.As(err, &unavailable) {
+			return false, unavailable.Until, err
+		}
+		// 等待其他请求获取robots.txt超时
+		return false, time.Now().Add(robotsTimeout), err
+	}
+	if delay := c.robots.CrawlDelay(ctx, u); delay > 0 {
+		if s, ok := c.scheduler.(hostDelayer); ok {
+			s.SetMinHostDelay(u.Host, delay)
+		}
+	}
+
+	return ok, time.Time{}, nil
+}
+
+// robotsFetch 通过任务的Fetcher获取robots.txt，与任务的请求使用相同的代理与传输配置
+func robotsFetch(task *collect.Task) robots.FetchFunc {
+	return func(ctx context.Context, u string) (int, []byte, error) {
+		resp, err := task.Fetcher.Get(ctx, &collect.Request{Task: task, Url: u, Method: http.MethodGet})
+		// 状态码不是200时Fetcher同时返回响应与错误
+		if resp != nil {
+			return resp.StatusCode, resp.Body, nil
+		}
+		return 0, nil, err
+	}
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/awaketai/crawler/robots"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRobotsUnavailableDefers robots.txt暂时无法获取时推迟请求，恢复后正常爬取
+func TestRobotsUnavailableDefers(t *testing.T) {
+	addTestTask("test_robots_defer", "http://example.com/a", "http://example.com/private")
+	var robotsCalls int32
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		resp := okResponse(req)
+		if req.Url == "http://example.com/robots.txt" {
+			if atomic.AddInt32(&robotsCalls, 1) == 1 {
+				resp.StatusCode = http.StatusServiceUnavailable
+				return resp, &collect.StatusError{StatusCode: resp.StatusCode}
+			}
+			resp.Body = []byte("User-agent: *\nDisallow: /private\n")
+		}
+		return resp, nil
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_robots_defer"),
+		collect.WithFetcher(fetcher),
+		collect.WithWaitTime(0),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+		WithRobots(robots.NewCache(robots.WithErrorTTL(50*time.Millisecond))),
+	)
+
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(1), summary.Total.Requests)
+	assert.Equal(t, int64(1), summary.Total.Dropped)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&robotsCalls))
+}
+
+// TestRobotsNeverRecovers robots.txt一直无法获取时推迟次数受重试策略限制，请求最终进入死信队列
+func TestRobotsNeverRecovers(t *testing.T) {
+	addTestTask("test_robots_down", "http://example.com/a", "http://example.com/b")
+	var pages int32
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		resp := okResponse(req)
+		if req.Url == "http://example.com/robots.txt" {
+			resp.StatusCode = http.StatusServiceUnavailable
+			return resp, &collect.StatusError{StatusCode: resp.StatusCode}
+		}
+		atomic.AddInt32(&pages, 1)
+		return resp, nil
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_robots_down"),
+		collect.WithFetcher(fetcher),
+		collect.WithWaitTime(0),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+		WithRobots(robots.NewCache(robots.WithErrorTTL(10*time.Millisecond))),
+		WithRetryPolicy(collect.RetryPolicy{MaxAttempts: 3}),
+	)
+
+	done := make(chan Summary)
+	go func() {
+		done <- c.Run(context.Background())
+	}()
+	var summary Summary
+	select {
+	case summary = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("crawler did not finish while robots.txt is unavailable")
+	}
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(0), summary.Total.Requests)
+	assert.Equal(t, int64(2), summary.Total.DeadLetters)
+	assert.Equal(t, int64(4), summary.Total.Retries)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pages))
+	for _, letter := range c.DeadLetters().List() {
+		assert.Equal(t, 3, letter.Attempts)
+		assert.Contains(t, letter.Err, "robots")
+	}
+}
@@ -1,10 +1,18 @@
 package engine
 
 import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/awaketai/crawler/collect"
 	"go.uber.org/zap"
 )
 
+var errScheduleStopped = errors.New("schedule stopped")
+
 // Schedule
 // 1.创建调度程序，接收任务并将任务存储起来
 // 2.执行调度任务，通过一定的调度算法将任务调度到合适的worker
@@ -15,71 +23,196 @@ type Schedule struct {
 	requestCh chan *collect.Request
 	// workerCh 分配任务给worker
 	workerCh chan *collect.Request
-	reqQueue []*collect.Request
-	Logger   *zap.Logger
-	// priReqQueue 优先队列
-	priReqQueue []*collect.Request
+	// ackCh 接收处理完成的请求
+	ackCh chan *collect.Request
+	// delayed 未到NotBefore时间的请求
+	delayed delayQueue
+	// hosts 按站点划分的待调度请求，ring为站点轮询顺序
+	hosts  map[string]*hostQueue
+	ring   []string
+	next   int
+	queued int
+	start  time.Time
+	// hostDelays 单独设置的站点请求间隔
+	hostDelays map[string]time.Duration
+	// coolDowns 站点冷却结束的时间
+	coolDowns map[string]time.Time
+	hostMu    sync.Mutex
+	Logger    *zap.Logger
+	// done 调度结束后关闭
+	done chan struct{}
+	scheduleOptions
 }
 
-func NewSchedule() *Schedule {
+func NewSchedule(opts ...ScheduleOption) *Schedule {
+	options := defaultScheduleOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
 	s := &Schedule{}
 	requestCh := make(chan *collect.Request)
 	worketCh := make(chan *collect.Request)
 	s.requestCh = requestCh
 	s.workerCh = worketCh
+	s.done = make(chan struct{})
+	s.ackCh = make(chan *collect.Request)
+	s.hosts = map[string]*hostQueue{}
+	s.hostDelays = map[string]time.Duration{}
+	s.coolDowns = map[string]time.Time{}
+	s.start = time.Now()
+	s.scheduleOptions = options
+	s.Logger = options.logger
 
 	return s
 }
 
+// Push 调度结束后加入的请求不再调度，开启持久化时仍会被保存，下次运行时恢复
 func (s *Schedule) Push(reqs ...*collect.Request) {
 	for _, req := range reqs {
-		s.requestCh <- req
+		if s.store != nil {
+			if err := s.store.Add(req); err != nil {
+				s.Logger.Error("queue store add failed", zap.String("url", req.Url), zap.Error(err))
+			}
+		}
+		select {
+		case s.requestCh <- req:
+		case <-s.done:
+		}
+	}
+}
+
+// Restore 返回上次运行未完成的请求
+func (s *Schedule) Restore() ([]*collect.Request, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	return s.store.Load()
+}
+
+// Ack 请求处理完成后释放站点连接数，并从持久化存储中移除
+func (s *Schedule) Ack(req *collect.Request) {
+	if s.store != nil {
+		if err := s.store.Remove(req); err != nil {
+			s.Logger.Error("queue store remove failed", zap.String("url", req.Url), zap.Error(err))
+		}
 	}
+	s.Release(req)
 }
 
-func (s *Schedule) Pull() *collect.Request {
-	r := <-s.workerCh
-	return r
+// Release 只释放站点连接数，重试的请求保留在持久化存储中
+func (s *Schedule) Release(req *collect.Request) {
+	select {
+	case s.ackCh <- req:
+	case <-s.done:
+	}
 }
 
-func (s *Schedule) Schedule() {
+func (s *Schedule) Pull(ctx context.Context) (*collect.Request, error) {
+	select {
+	case r := <-s.workerCh:
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-s.done:
+		return nil, errScheduleStopped
+	}
+}
+
+// Schedule 各站点按优先级和轮询顺序分配请求
+// 同一站点两次分配之间至少间隔站点延迟加上任务WaitTime内的随机时长，且进行中的请求数不超过maxHostConns
+// 等待期间worker不被占用，可以处理其他站点的请求
+// 设置了NotBefore的请求在到期前保存在延迟队列中，不占用worker
+// 内存中的请求数达到maxQueueLen后，新请求溢出到磁盘，队列有空位时按顺序读回
+func (s *Schedule) Schedule(ctx context.Context) {
 	var (
-		req *collect.Request
-		ch  chan *collect.Request
+		req   *collect.Request
+		ch    chan *collect.Request
+		timer = time.NewTimer(time.Hour)
+		spill = newSpillQueue(s.spillDir)
 	)
-	
+	timer.Stop()
+	defer timer.Stop()
+	defer close(s.done)
+	defer spill.close()
+
 	for {
-		if req == nil && len(s.priReqQueue) > 0 {
-			// 先执行优先队列中的任务
-			req = s.priReqQueue[0]
-			s.priReqQueue = s.priReqQueue[1:]
-			ch = s.workerCh
+		var (
+			wakeup <-chan time.Time
+			now    = time.Now()
+		)
+		dueReqs, delayWait := s.delayed.due(now)
+		for _, r := range dueReqs {
+			s.pushHost(r)
+		}
+		for spill.Len() > 0 && !s.full() {
+			r, err := spill.pop()
+			if err != nil {
+				s.Logger.Error("read spilled request failed", zap.Error(err))
+				continue
+			}
+			s.enqueue(r)
+		}
+		wait := delayWait
+		if req == nil {
+			var hostWait time.Duration
+			req, hostWait = s.popHost(now)
+			if req != nil {
+				ch = s.workerCh
+			} else if hostWait > 0 && (wait == 0 || hostWait < wait) {
+				wait = hostWait
+			}
 		}
-		if req == nil && len(s.reqQueue) > 0 {
-			req = s.reqQueue[0]
-			s.reqQueue = s.reqQueue[1:]
-			ch = s.workerCh
+		if wait > 0 {
+			timer.Reset(wait)
+			wakeup = timer.C
 		}
 		select {
 		case r := <-s.requestCh:
-			if r.Priority > 0 {
-				s.priReqQueue = append(s.priReqQueue, r)
-			} else {
-				s.reqQueue = append(s.reqQueue, r)
+			// 已有请求溢出时新请求也写入磁盘，保持先后顺序，写入失败时保留在内存中
+			if !s.full() && spill.Len() == 0 {
+				s.enqueue(r)
+			} else if err := spill.push(r); err != nil {
+				s.Logger.Error("spill request failed", zap.String("url", r.Url), zap.Error(err))
+				s.enqueue(r)
 			}
+		case r := <-s.ackCh:
+			s.ackHost(r)
 		case ch <- req:
 			req = nil
 			ch = nil
+		case <-wakeup:
+		case <-ctx.Done():
+			return
 		}
+		if wakeup != nil && !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+}
+
+// enqueue 未到NotBefore的请求放入延迟队列，其余按站点排队
+func (s *Schedule) enqueue(r *collect.Request) {
+	if r.NotBefore.After(time.Now()) {
+		heap.Push(&s.delayed, r)
+	} else {
+		s.pushHost(r)
 	}
 }
 
+// full 内存中排队和延迟的请求数达到maxQueueLen
+func (s *Schedule) full() bool {
+	return s.maxQueueLen > 0 && s.queued+s.delayed.Len() >= s.maxQueueLen
+}
+
 // 避免重复请求
 // 1.用什么数据结构存储数据才能保证快速地查找到请求的记录：哈希表
 // 2.如何保证并发查找与写入时，不出现并发冲突问题：加入互扩锁
 // 3.在什么条件下，我们才能确认请求是重复的，从而停止爬取
 
-func GetFields(taskName,ruleName string) []string {
+func GetFields(taskName, ruleName string) []string {
 
 	return Store.hash[taskName].Rule.Trunk[ruleName].ItemFields
-}
\ No newline at end of file
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type ScheduleOption func(opts *scheduleOptions)
+
+type scheduleOptions struct {
+	logger *zap.Logger
+	// store 队列持久化存储，为空时仅保存在内存中
+	store QueueStore
+	// aging 低优先级请求每等待aging时长，优先级提高1，为0时不提高
+	aging time.Duration
+	// maxQueueLen 内存中排队和延迟的请求数上限，超过的请求溢出到spillDir下的临时文件，为0时不限制
+	maxQueueLen int
+	// spillDir 溢出文件所在目录，为空时使用系统临时目录
+	spillDir string
+	// defaultHostDelay 同一站点两次请求之间的最小间隔
+	defaultHostDelay time.Duration
+	// maxHostConns 同一站点同时进行的最大请求数，为0时不限制
+	maxHostConns int
+	// strategy 相同优先级请求的出队顺序
+	strategy Strategy
+}
+
+var defaultScheduleOptions = scheduleOptions{
+	logger: zap.NewNop(),
+}
+
+func WithScheduleLogger(logger *zap.Logger) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.logger = logger
+	}
+}
+
+func WithQueueStore(store QueueStore) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.store = store
+	}
+}
+
+func WithAging(aging time.Duration) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.aging = aging
+	}
+}
+
+func WithMaxQueueLen(n int) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.maxQueueLen = n
+	}
+}
+
+func WithSpillDir(dir string) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.spillDir = dir
+	}
+}
+
+func WithHostDelay(delay time.Duration) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.defaultHostDelay = delay
+	}
+}
+
+func WithMaxHostConns(n int) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.maxHostConns = n
+	}
+}
+
+func WithStrategy(strategy Strategy) ScheduleOption {
+	return func(opts *scheduleOptions) {
+		opts.strategy = strategy
+	}
+}
@@ -1,9 +1,21 @@
 package engine
 
-import "github.com/awaketai/crawler/collect"
+import (
+	"context"
+
+	"github.com/awaketai/crawler/collect"
+)
 
 type Scheduler interface {
-	Schedule()
+	// Schedule 执行调度，ctx取消后返回
+	Schedule(ctx context.Context)
 	Push(...*collect.Request)
-	Pull() *collect.Request
+	// Pull 获取待处理的请求，ctx取消或调度停止时返回错误
+	Pull(ctx context.Context) (*collect.Request, error)
+	// Ack 请求处理完成（成功、丢弃或进入死信队列），不会再被调度
+	Ack(*collect.Request)
+	// Release 请求本次处理结束，之后还会重试
+	Release(*collect.Request)
+	// Restore 返回上次运行未完成的请求，未开启持久化时返回空
+	Restore() ([]*collect.Request, error)
 }
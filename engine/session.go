@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/awaketai/crawler/collect"
+	"go.uber.org/zap"
+)
+
+// initSessions 为配置了账号、会话文件或需要登录的任务创建会话池，并从会话文件恢复cookie
+func (c *Crawler) initSessions(task, seed *collect.Task) {
+	task.Accounts = seed.Accounts
+	task.SessionFile = seed.SessionFile
+	task.Sessions = seed.Sessions
+	if task.Sessions == nil && (len(task.Accounts) > 0 || task.SessionFile != "" || task.Rule.Auth != nil) {
+		accounts := task.Accounts
+		if len(accounts) == 0 {
+			accounts = []collect.Account{{Cookie: task.Cookie}}
+		}
+		task.Sessions = collect.NewSessionPool(accounts...)
+	}
+	if task.Sessions == nil || task.SessionFile == "" {
+		return
+	}
+	if err := task.Sessions.Load(task.SessionFile); err != nil {
+		c.Logger.Error("load sessions failed", zap.String("task", task.Name), zap.Error(err))
+	}
+}
+
+// saveSessions 保存各任务会话的cookie
+func (c *Crawler) saveSessions() {
+	for _, seed := range c.Seeds {
+		task := Store.hash[seed.Name]
+		if task == nil || task.Sessions == nil || task.SessionFile == "" {
+			continue
+		}
+		if err := task.Sessions.Save(task.SessionFile); err != nil {
+			c.Logger.Error("save sessions failed", zap.String("task", task.Name), zap.Error(err))
+		}
+	}
+}
+
+// login 任务需要登录时，确保请求使用的会话已登录，返回登录的代数
+func (c *Crawler) login(ctx context.Context, r *collect.Request) (int, error) {
+	auth := r.Task.Rule.Auth
+	session := r.Session()
+	if auth == nil || session == nil {
+		return 0, nil
+	}
+	return session.EnsureLogin(ctx, r.Task, auth)
+}
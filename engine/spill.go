@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/awaketai/crawler/collect"
+)
+
+// spillEntry 溢出的请求，QueueID保证读回后仍对应持久化存储中的记录
+type spillEntry struct {
+	QueueID uint64        `json:"queue_id,omitempty"`
+	Req     RequestRecord `json:"req"`
+}
+
+// spillQueue 调度队列已满时溢出到磁盘的请求，按加入顺序读回
+// 请求以RequestRecord保存，读回时从Store中查找任务，ParseFunc、TestBody等无法序列化的字段不保留
+// 只在调度协程中使用，不加锁，读空后删除文件
+type spillQueue struct {
+	dir string
+	w   *os.File
+	enc *json.Encoder
+	r   *os.File
+	dec *json.Decoder
+	n   int
+}
+
+func newSpillQueue(dir string) *spillQueue {
+	return &spillQueue{dir: dir}
+}
+
+func (q *spillQueue) Len() int {
+	return q.n
+}
+
+func (q *spillQueue) push(req *collect.Request) error {
+	if q.w == nil {
+		if err := q.open(); err != nil {
+			return err
+		}
+	}
+	if err := q.enc.Encode(spillEntry{QueueID: req.QueueID, Req: NewRequestRecord(req)}); err != nil {
+		return err
+	}
+	q.n++
+	return nil
+}
+
+// pop 读回最早溢出的请求，任务不存在等无法还原的请求返回错误并被丢弃
+func (q *spillQueue) pop() (*collect.Request, error) {
+	if q.n == 0 {
+		return nil, nil
+	}
+	var entry spillEntry
+	err := q.dec.Decode(&entry)
+	q.n--
+	if q.n == 0 {
+		q.close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	req, err := entry.Req.Request()
+	if err != nil {
+		return nil, err
+	}
+	req.QueueID = entry.QueueID
+	return req, nil
+}
+
+func (q *spillQueue) open() error {
+	w, err := os.CreateTemp(q.dir, "crawler-spill-*.jsonl")
+	if err != nil {
+		return err
+	}
+	r, err := os.Open(w.Name())
+	if err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return err
+	}
+	q.w, q.enc = w, json.NewEncoder(w)
+	q.r, q.dec = r, json.NewDecoder(r)
+	return nil
+}
+
+// close 关闭并删除溢出文件，未读回的请求被丢弃
+func (q *spillQueue) close() {
+	if q.w == nil {
+		return
+	}
+	q.r.Close()
+	q.w.Close()
+	os.Remove(q.w.Name())
+	q.w, q.enc, q.r, q.dec = nil, nil, nil, nil
+	q.n = 0
+}
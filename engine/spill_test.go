@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleSpill(t *testing.T) {
+	addTestTask("test_spill")
+	task := Store.hash["test_spill"]
+	dir := t.TempDir()
+	s := NewSchedule(WithMaxQueueLen(2), WithSpillDir(dir))
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Schedule(ctx)
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		s.Push(&collect.Request{
+			Task:     task,
+			Url:      fmt.Sprintf("http://example.com/%d", i),
+			RuleName: "page",
+		})
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	pullCtx, pullCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer pullCancel()
+	for i := 0; i < total; i++ {
+		req, err := s.Pull(pullCtx)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("http://example.com/%d", i), req.Url)
+		assert.Same(t, task, req.Task)
+		s.Ack(req)
+	}
+	cancel()
+	<-s.done
+	assert.Zero(t, s.queued+s.delayed.Len())
+	// 读空后删除溢出文件
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestScheduleSpillBounded(t *testing.T) {
+	addTestTask("test_spill_bounded")
+	task := Store.hash["test_spill_bounded"]
+	dir := t.TempDir()
+	s := NewSchedule(WithMaxQueueLen(3), WithSpillDir(dir))
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Schedule(ctx)
+
+	for i := 0; i < 50; i++ {
+		s.Push(&collect.Request{
+			Task:      task,
+			Url:       fmt.Sprintf("http://example.com/%d", i),
+			RuleName:  "page",
+			NotBefore: time.Now().Add(time.Hour),
+		})
+	}
+	cancel()
+	<-s.done
+	// 延迟的请求也计入上限，其余请求在磁盘上
+	assert.Equal(t, 3, s.queued+s.delayed.Len())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
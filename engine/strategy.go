@@ -0,0 +1,45 @@
+package engine
+
+import "github.com/awaketai/crawler/collect"
+
+// Strategy 爬取策略，决定相同优先级请求的出队顺序
+// 零值按入队顺序先进先出
+type Strategy struct {
+	// score 得分越高越先出队，为空时所有请求得分相同
+	score func(*collect.Request) float64
+	// lifo 得分相同时后入队的先出队
+	lifo bool
+}
+
+var (
+	// BreadthFirst 广度优先，深度小的请求先出队
+	BreadthFirst = Strategy{
+		score: func(r *collect.Request) float64 {
+			return -float64(r.Depth)
+		},
+	}
+	// DepthFirst 深度优先，深度大的请求先出队，深度相同时后发现的先出队
+	DepthFirst = Strategy{
+		score: func(r *collect.Request) float64 {
+			return float64(r.Depth)
+		},
+		lifo: true,
+	}
+)
+
+// BestFirst 最佳优先，score返回值越大越先出队
+func BestFirst(score func(*collect.Request) float64) Strategy {
+	return Strategy{
+		score: score,
+	}
+}
+
+func (s Strategy) less(a, b *queueItem) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	if s.lifo {
+		return a.seq > b.seq
+	}
+	return a.seq < b.seq
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/awaketai/crawler/limiter"
+)
+
+// TaskStats 单个任务的统计
+type TaskStats struct {
+	Requests    int64 // 成功获取的请求数
+	Failures    int64 // 失败的请求数，包含之后重试成功的
+	Retries     int64 // 重试次数
+	DeadLetters int64 // 重试耗尽的请求数
+	Dropped     int64 // 因超过深度、重复访问等被丢弃的请求数
+	Items       int64 // 产出的数据条数
+	Unchanged   int64 // 内容未变化而跳过解析的请求数
+	Bans        int64 // 被封禁的次数
+}
+
+func (t *TaskStats) add(o TaskStats) {
+	t.Requests += o.Requests
+	t.Failures += o.Failures
+	t.Retries += o.Retries
+	t.DeadLetters += o.DeadLetters
+	t.Dropped += o.Dropped
+	t.Items += o.Items
+	t.Unchanged += o.Unchanged
+	t.Bans += o.Bans
+}
+
+// Summary 一次运行的统计
+type Summary struct {
+	Start time.Time
+	End   time.Time
+	// Completed 为true表示所有请求都已处理完毕，否则为ctx取消导致的停止
+	Completed bool
+	// Pending 停止时尚未完成的请求数
+	Pending int
+	// Total 所有任务的汇总
+	Total TaskStats
+	Tasks map[string]TaskStats
+	// Limits 限流器统计，可据此找出限制速度的限流器
+	Limits map[string]limiter.Stats
+}
+
+func (s Summary) Elapsed() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type crawlStats struct {
+	mu    sync.Mutex
+	tasks map[string]*TaskStats
+}
+
+func newCrawlStats() *crawlStats {
+	return &crawlStats{
+		tasks: map[string]*TaskStats{},
+	}
+}
+
+func (s *crawlStats) add(task string, delta TaskStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tasks[task]
+	if !ok {
+		t = &TaskStats{}
+		s.tasks[task] = t
+	}
+	t.add(delta)
+}
+
+func (c *Crawler) summary(start time.Time, completed bool) Summary {
+	sum := Summary{
+		Start:     start,
+		End:       time.Now(),
+		Completed: completed,
+		Pending:   c.track.Pending(),
+		Tasks:     map[string]TaskStats{},
+		Limits:    c.limitStats(),
+	}
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+	for name, t := range c.stats.tasks {
+		sum.Tasks[name] = *t
+		sum.Total.add(*t)
+	}
+
+	return sum
+}
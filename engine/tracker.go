@@ -0,0 +1,49 @@
+package engine
+
+import "sync"
+
+// tracker 统计尚未完成的请求，包括排队中、处理中以及等待重试的请求
+// 数量归零时说明所有种子的请求树都已处理完毕
+type tracker struct {
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+	closed  bool
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		idle: make(chan struct{}),
+	}
+}
+
+// Add 新请求进入爬取流程前调用
+func (t *tracker) Add(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending += n
+	t.check()
+}
+
+// Done 请求处理完成（成功、丢弃或进入死信队列）后调用
+func (t *tracker) Done() {
+	t.Add(-1)
+}
+
+func (t *tracker) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending
+}
+
+// Idle 所有请求完成后关闭
+func (t *tracker) Idle() <-chan struct{} {
+	return t.idle
+}
+
+func (t *tracker) check() {
+	if t.pending <= 0 && !t.closed {
+		t.closed = true
+		close(t.idle)
+	}
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"go.uber.org/zap"
+)
+
+// hostCooler 支持站点冷却的调度器
+type hostCooler interface {
+	CoolDown(host string, d time.Duration)
+}
+
+// validate 校验响应，规则允许交给ParseFunc处理的非200状态码不经过校验
+// 其他非200响应只采纳Ban结果用于识别封禁，其余情况按状态码经过重试策略判断是否重试
+func validate(rule *collect.Rule, resp *collect.Response, validators []collect.Validator) (collect.Verdict, string) {
+	if resp == nil {
+		return collect.Success, ""
+	}
+	if resp.StatusCode == http.StatusOK {
+		return collect.Validate(resp, validators)
+	}
+	if rule != nil && rule.Allowed(resp.StatusCode) {
+		return collect.Success, ""
+	}
+	if verdict, reason := collect.Validate(resp, validators); verdict == collect.Ban {
+		return verdict, reason
+	}
+	return collect.Success, ""
+}
+
+// reject 响应未通过校验，被封禁时让站点冷却并更换代理，之后按重试策略重试
+func (c *Crawler) reject(r *collect.Request, resp *collect.Response, err *collect.ValidationError) {
+	if err.Verdict != collect.Ban {
+		c.Logger.Error("response validation failed", zap.String("url", r.Url), zap.Error(err))
+		c.SetFailure(r, err)
+		return
+	}
+	c.Logger.Error("fetch be banned", zap.String("url", r.Url), zap.Error(err))
+	c.stats.add(r.Task.Name, TaskStats{Bans: 1})
+	if r.Task.BanCoolDown > 0 {
+		if s, ok := c.scheduler.(hostCooler); ok {
+			s.CoolDown(requestHost(r), time.Duration(r.Task.BanCoolDown)*time.Second)
+		}
+	}
+	if br, ok := r.Task.Fetcher.(collect.BanReporter); ok {
+		br.ReportBan(r, resp)
+	}
+	if r.Task.OnBan != nil {
+		r.Task.OnBan(r, resp)
+	}
+	c.SetFailure(r, err)
+}
@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// banFetcher 第一次请求返回403，记录ReportBan的调用次数
+type banFetcher struct {
+	calls   int
+	reports int
+}
+
+func (f *banFetcher) Get(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+	f.calls++
+	resp := okResponse(req)
+	if f.calls == 1 {
+		resp.StatusCode = http.StatusForbidden
+		return resp, &collect.StatusError{StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func (f *banFetcher) ReportBan(*collect.Request, *collect.Response) {
+	f.reports++
+}
+
+func TestBanReportedToFetcher(t *testing.T) {
+	addTestTask("test_ban_report", "http://example.com/a")
+	fetcher := &banFetcher{}
+	seed := collect.NewTask(
+		collect.WithName("test_ban_report"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+		collect.WithWaitTime(0),
+		collect.WithBanCoolDown(0),
+		collect.WithValidators(collect.StatusValidator(collect.Ban, http.StatusForbidden)),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+		WithRetryPolicy(collect.RetryPolicy{MaxAttempts: 2, BaseDelay: 1}),
+	)
+
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	assert.Equal(t, int64(1), summary.Total.Bans)
+	assert.Equal(t, int64(1), summary.Total.Requests)
+	assert.Equal(t, 1, fetcher.reports)
+}
+
+func TestAllowedStatusSkipsValidators(t *testing.T) {
+	rule := &collect.Rule{AllowStatus: []int{http.StatusNotFound}}
+	validators := []collect.Validator{collect.StatusValidator(collect.Ban, http.StatusNotFound, http.StatusForbidden)}
+
+	verdict, _ := validate(rule, &collect.Response{StatusCode: http.StatusNotFound}, validators)
+	assert.Equal(t, collect.Success, verdict)
+	verdict, _ = validate(rule, &collect.Response{StatusCode: http.StatusForbidden}, validators)
+	assert.Equal(t, collect.Ban, verdict)
+	verdict, _ = validate(nil, &collect.Response{StatusCode: http.StatusNotFound}, validators)
+	assert.Equal(t, collect.Ban, verdict)
+
+	// 非200响应的内容校验失败不代替状态码的重试判断
+	validators = []collect.Validator{collect.MinSizeValidator(10, collect.SoftFailure)}
+	verdict, _ = validate(nil, &collect.Response{StatusCode: http.StatusNotFound}, validators)
+	assert.Equal(t, collect.Success, verdict)
+	verdict, _ = validate(nil, &collect.Response{StatusCode: http.StatusOK}, validators)
+	assert.Equal(t, collect.SoftFailure, verdict)
+}
+
+func TestNotFoundSkipsSizeValidator(t *testing.T) {
+	addTestTask("test_not_found_size", "http://example.com/missing")
+	calls := 0
+	fetcher := fetchFunc(func(ctx context.Context, req *collect.Request) (*collect.Response, error) {
+		calls++
+		resp := okResponse(req)
+		resp.StatusCode = http.StatusNotFound
+		resp.Status = "404 Not Found"
+		return resp, &collect.StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	})
+	seed := collect.NewTask(
+		collect.WithName("test_not_found_size"),
+		collect.WithFetcher(fetcher),
+		collect.WithIgnoreRobots(true),
+		collect.WithWaitTime(0),
+		collect.WithValidators(collect.MinSizeValidator(100, collect.SoftFailure)),
+	)
+	c := NewCrawler(
+		WithTasks([]*collect.Task{seed}),
+		WithWorkCount(1),
+		WithScheduler(NewSchedule()),
+		WithRetryPolicy(collect.RetryPolicy{MaxAttempts: 3, BaseDelay: 1}),
+	)
+
+	summary := c.Run(context.Background())
+	assert.True(t, summary.Completed)
+	// 404不在可重试的状态码中，直接进入死信队列
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, int64(0), summary.Total.Retries)
+	assert.Equal(t, int64(1), summary.Total.DeadLetters)
+	letters := c.DeadLetters().List()
+	require.Len(t, letters, 1)
+	assert.Contains(t, letters[0].Err, "404")
+}
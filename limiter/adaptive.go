@@ -0,0 +1,213 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Feedback 一次请求的结果，用于调整限流速率
+type Feedback struct {
+	StatusCode int
+	// RetryAfter 响应头Retry-After要求的等待时长
+	RetryAfter time.Duration
+	Latency    time.Duration
+	// Banned 响应被识别为封禁
+	Banned bool
+	// Err 请求失败且没有响应时的错误
+	Err error
+}
+
+// FeedbackLimiter 可以根据请求结果调整速率的限流器
+type FeedbackLimiter interface {
+	RateLimiter
+	Feedback(f Feedback)
+}
+
+// RetryAfter 解析Retry-After响应头，支持秒数与HTTP日期两种格式
+func RetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+type adaptiveOptions struct {
+	// increase 每次成功请求增加的速率
+	increase rate.Limit
+	// decrease 拥塞时速率乘以的系数
+	decrease float64
+	// latencyFactor 延迟超过平均延迟的倍数时视为拥塞，为0时不检测延迟
+	latencyFactor float64
+	// backoffInterval 两次降速的最小间隔，避免同一批失败请求使速率连续下降
+	backoffInterval time.Duration
+	burst           int
+}
+
+var defaultAdaptiveOptions = adaptiveOptions{
+	decrease:        0.5,
+	latencyFactor:   3,
+	backoffInterval: time.Second,
+	burst:           1,
+}
+
+type AdaptiveOption func(opts *adaptiveOptions)
+
+// WithIncrease 每次成功请求增加的速率，默认为最大速率的5%
+func WithIncrease(increase rate.Limit) AdaptiveOption {
+	return func(opts *adaptiveOptions) {
+		opts.increase = increase
+	}
+}
+
+func WithDecrease(factor float64) AdaptiveOption {
+	return func(opts *adaptiveOptions) {
+		opts.decrease = factor
+	}
+}
+
+func WithLatencyFactor(factor float64) AdaptiveOption {
+	return func(opts *adaptiveOptions) {
+		opts.latencyFactor = factor
+	}
+}
+
+func WithBackoffInterval(interval time.Duration) AdaptiveOption {
+	return func(opts *adaptiveOptions) {
+		opts.backoffInterval = interval
+	}
+}
+
+func WithBurst(burst int) AdaptiveOption {
+	return func(opts *adaptiveOptions) {
+		opts.burst = burst
+	}
+}
+
+// AdaptiveLimiter 根据服务端反馈调整速率的限流器（AIMD）
+// 成功时线性提速，遇到429/503、Retry-After、封禁或延迟明显升高时按比例降速，速率在[min,max]之间
+type AdaptiveLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	min     rate.Limit
+	max     rate.Limit
+	// latency 平均延迟
+	latency     time.Duration
+	lastBackoff time.Time
+	// pausedUntil Retry-After要求的暂停结束时间
+	pausedUntil time.Time
+	stats       *waitStats
+	adaptiveOptions
+}
+
+func NewAdaptiveLimiter(min, max rate.Limit, opts ...AdaptiveOption) *AdaptiveLimiter {
+	options := defaultAdaptiveOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.increase <= 0 {
+		options.increase = max / 20
+	}
+	if options.burst < 1 {
+		options.burst = 1
+	}
+	if min > max {
+		min = max
+	}
+	return &AdaptiveLimiter{
+		limiter:         rate.NewLimiter(max, options.burst),
+		min:             min,
+		max:             max,
+		stats:           newWaitStats(),
+		adaptiveOptions: options,
+	}
+}
+
+func (l *AdaptiveLimiter) Wait(ctx context.Context) error {
+	defer l.stats.record(time.Now())
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// Limit 当前速率
+func (l *AdaptiveLimiter) Limit() rate.Limit {
+	return l.limiter.Limit()
+}
+
+func (l *AdaptiveLimiter) Stats() Stats {
+	s := Stats{
+		Limit:  l.limiter.Limit(),
+		Burst:  l.limiter.Burst(),
+		Tokens: l.limiter.Tokens(),
+	}
+	l.stats.fill(&s)
+	return s
+}
+
+func (l *AdaptiveLimiter) Feedback(f Feedback) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if f.RetryAfter > 0 && now.Add(f.RetryAfter).After(l.pausedUntil) {
+		l.pausedUntil = now.Add(f.RetryAfter)
+	}
+	congested := f.Banned || f.RetryAfter > 0 ||
+		f.StatusCode == http.StatusTooManyRequests || f.StatusCode == http.StatusServiceUnavailable
+	if f.Latency > 0 {
+		if l.latencyFactor > 0 && l.latency > 0 && float64(f.Latency) > float64(l.latency)*l.latencyFactor {
+			congested = true
+		}
+		// 平均延迟使用指数加权移动平均
+		if l.latency == 0 {
+			l.latency = f.Latency
+		} else {
+			l.latency = (l.latency*7 + f.Latency) / 8
+		}
+	}
+
+	current := l.limiter.Limit()
+	switch {
+	case congested:
+		if now.Sub(l.lastBackoff) < l.backoffInterval {
+			return
+		}
+		l.lastBackoff = now
+		next := rate.Limit(float64(current) * l.decrease)
+		if next < l.min {
+			next = l.min
+		}
+		l.limiter.SetLimitAt(now, next)
+	case f.Err == nil && f.StatusCode >= 200 && f.StatusCode < 400:
+		next := current + l.increase
+		if next > l.max {
+			next = l.max
+		}
+		l.limiter.SetLimitAt(now, next)
+	}
+}
@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestAdaptiveLimiter(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 16, WithIncrease(2), WithBackoffInterval(0), WithLatencyFactor(0))
+	assert.Equal(t, rate.Limit(16), l.Limit())
+
+	l.Feedback(Feedback{StatusCode: http.StatusTooManyRequests})
+	assert.Equal(t, rate.Limit(8), l.Limit())
+	l.Feedback(Feedback{StatusCode: http.StatusOK, Banned: true})
+	assert.Equal(t, rate.Limit(4), l.Limit())
+	for i := 0; i < 5; i++ {
+		l.Feedback(Feedback{StatusCode: http.StatusServiceUnavailable})
+	}
+	assert.Equal(t, rate.Limit(1), l.Limit())
+
+	// 404等非拥塞错误不改变速率
+	l.Feedback(Feedback{StatusCode: http.StatusNotFound})
+	assert.Equal(t, rate.Limit(1), l.Limit())
+	for i := 0; i < 20; i++ {
+		l.Feedback(Feedback{StatusCode: http.StatusOK})
+	}
+	assert.Equal(t, rate.Limit(16), l.Limit())
+}
+
+func TestAdaptiveLimiterLatency(t *testing.T) {
+	l := NewAdaptiveLimiter(1, 10, WithBackoffInterval(time.Hour))
+	for i := 0; i < 10; i++ {
+		l.Feedback(Feedback{StatusCode: http.StatusOK, Latency: 100 * time.Millisecond})
+	}
+	l.Feedback(Feedback{StatusCode: http.StatusOK, Latency: time.Second})
+	assert.Equal(t, rate.Limit(5), l.Limit())
+	// 降速间隔内不会再次降速
+	l.Feedback(Feedback{StatusCode: http.StatusOK, Latency: 5 * time.Second})
+	assert.Equal(t, rate.Limit(5), l.Limit())
+}
+
+func TestAdaptiveLimiterRetryAfter(t *testing.T) {
+	l := NewAdaptiveLimiter(rate.Inf, rate.Inf)
+	l.Feedback(Feedback{StatusCode: http.StatusTooManyRequests, RetryAfter: RetryAfter("1")})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+
+	multi := NewMultiLimit(NewLimiter(rate.Inf, 1), NewAdaptiveLimiter(1, 4, WithBackoffInterval(0)))
+	multi.Feedback(Feedback{StatusCode: http.StatusServiceUnavailable})
+	assert.Equal(t, rate.Limit(2), multi.limiters[0].Limit())
+
+	assert.Equal(t, 120*time.Second, RetryAfter("120"))
+	assert.Zero(t, RetryAfter("-1"))
+	assert.InDelta(t, float64(time.Hour), float64(RetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))), float64(2*time.Second))
+}
@@ -0,0 +1,174 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/time/rate"
+)
+
+// bucket 保存在etcd中的令牌桶状态
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	// Last 上次更新令牌数的时间，单位纳秒
+	Last int64 `json:"last"`
+}
+
+// take 按速率补充令牌后取出一个，令牌不足时返回需要等待的时长
+func (b bucket) take(now time.Time, limit rate.Limit, burst int) (bucket, time.Duration) {
+	if limit == rate.Inf {
+		return b, 0
+	}
+	tokens := b.Tokens
+	if elapsed := now.UnixNano() - b.Last; elapsed > 0 {
+		tokens += float64(limit) * time.Duration(elapsed).Seconds()
+	}
+	if tokens > float64(burst) {
+		tokens = float64(burst)
+	}
+	if tokens < 1 {
+		if limit <= 0 {
+			return b, time.Duration(math.MaxInt64)
+		}
+		wait := time.Duration((1 - tokens) / float64(limit) * float64(time.Second))
+		return b, wait
+	}
+	return bucket{Tokens: tokens - 1, Last: now.UnixNano()}, 0
+}
+
+// EtcdLimiter 多个worker共享的令牌桶，桶的状态保存在etcd中，通过CAS更新
+// 同一key的所有worker合计速率不超过limit
+type EtcdLimiter struct {
+	client clientv3.KV
+	key    string
+	limit  rate.Limit
+	burst  int
+	// fallback etcd不可用时使用的本地限流器，为空时返回错误
+	fallback RateLimiter
+	stats    *waitStats
+	mu       sync.Mutex
+	// tokens 最近一次读取到的可用令牌数
+	tokens float64
+}
+
+type EtcdOption func(l *EtcdLimiter)
+
+// WithFallback etcd不可用时退化为本地限流
+func WithFallback(fallback RateLimiter) EtcdOption {
+	return func(l *EtcdLimiter) {
+		l.fallback = fallback
+	}
+}
+
+// NewEtcdLimiter client通常为*clientv3.Client，只使用其中的KV接口
+func NewEtcdLimiter(client clientv3.KV, key string, limit rate.Limit, burst int, opts ...EtcdOption) *EtcdLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &EtcdLimiter{
+		client: client,
+		key:    key,
+		limit:  limit,
+		burst:  burst,
+		stats:  newWaitStats(),
+		tokens: float64(burst),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+func (l *EtcdLimiter) Limit() rate.Limit {
+	return l.limit
+}
+
+// Stats 可用令牌数为本worker最近一次从etcd读取的值
+func (l *EtcdLimiter) Stats() Stats {
+	l.mu.Lock()
+	s := Stats{Limit: l.limit, Burst: l.burst, Tokens: l.tokens}
+	l.mu.Unlock()
+	l.stats.fill(&s)
+	return s
+}
+
+func (l *EtcdLimiter) Wait(ctx context.Context) error {
+	defer l.stats.record(time.Now())
+	for {
+		wait, err := l.reserve(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if l.fallback != nil {
+				return l.fallback.Wait(ctx)
+			}
+			return fmt.Errorf("etcd limiter %s: %w", l.key, err)
+		}
+		if wait == 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return fmt.Errorf("etcd limiter %s: wait %s would exceed context deadline", l.key, wait)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve 尝试取出一个令牌，返回0表示成功，否则返回需要等待的时长
+func (l *EtcdLimiter) reserve(ctx context.Context) (time.Duration, error) {
+	for {
+		resp, err := l.client.Get(ctx, l.key)
+		if err != nil {
+			return 0, err
+		}
+		now := time.Now()
+		cur := bucket{Tokens: float64(l.burst), Last: now.UnixNano()}
+		var cmp clientv3.Cmp
+		if len(resp.Kvs) == 0 {
+			cmp = clientv3.Compare(clientv3.CreateRevision(l.key), "=", 0)
+		} else {
+			kv := resp.Kvs[0]
+			if err := json.Unmarshal(kv.Value, &cur); err != nil {
+				return 0, err
+			}
+			cmp = clientv3.Compare(clientv3.ModRevision(l.key), "=", kv.ModRevision)
+		}
+		next, wait := cur.take(now, l.limit, l.burst)
+		l.mu.Lock()
+		l.tokens = next.Tokens
+		l.mu.Unlock()
+		if wait > 0 {
+			return wait, nil
+		}
+		b, err := json.Marshal(next)
+		if err != nil {
+			return 0, err
+		}
+		txn, err := l.client.Txn(ctx).If(cmp).Then(clientv3.OpPut(l.key, string(b))).Commit()
+		if err != nil {
+			return 0, err
+		}
+		if txn.Succeeded {
+			return 0, nil
+		}
+		// 其他worker同时取走了令牌，稍后重试
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(time.Duration(rand.Int63n(int64(10 * time.Millisecond)))):
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"golang.org/x/time/rate"
+)
+
+func TestBucketTake(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := bucket{Tokens: 2, Last: now.UnixNano()}
+
+	b, wait := b.take(now, 1, 2)
+	assert.Zero(t, wait)
+	b, wait = b.take(now, 1, 2)
+	assert.Zero(t, wait)
+	assert.Equal(t, float64(0), b.Tokens)
+
+	// 令牌不足时返回等待时长，状态不变
+	got, wait := b.take(now.Add(500*time.Millisecond), 1, 2)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	assert.Equal(t, b, got)
+
+	// 补充的令牌不超过桶容量
+	b, wait = b.take(now.Add(time.Hour), 1, 2)
+	assert.Zero(t, wait)
+	assert.Equal(t, float64(1), b.Tokens)
+
+	_, wait = bucket{}.take(now, rate.Inf, 1)
+	assert.Zero(t, wait)
+}
+
+// fakeKV 内存中的etcd KV，只实现EtcdLimiter使用的Get与Txn
+type fakeKV struct {
+	clientv3.KV
+	mu     sync.Mutex
+	value  []byte
+	create int64
+	mod    int64
+	rev    int64
+	// conflicts 之后的conflicts次事务提交前模拟其他worker修改了桶
+	conflicts int
+	txns      int
+	down      bool
+}
+
+var errEtcdDown = errors.New("etcd unavailable")
+
+func (kv *fakeKV) Get(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.down {
+		return nil, errEtcdDown
+	}
+	resp := &clientv3.GetResponse{}
+	if kv.value != nil {
+		resp.Kvs = []*mvccpb.KeyValue{{
+			Key:            []byte(key),
+			Value:          kv.value,
+			CreateRevision: kv.create,
+			ModRevision:    kv.mod,
+		}}
+	}
+	return resp, nil
+}
+
+func (kv *fakeKV) Txn(ctx context.Context) clientv3.Txn {
+	return &fakeTxn{kv: kv}
+}
+
+func (kv *fakeKV) put(value []byte) {
+	kv.rev++
+	if kv.value == nil {
+		kv.create = kv.rev
+	}
+	kv.value = value
+	kv.mod = kv.rev
+}
+
+func (kv *fakeKV) bucket(t *testing.T) bucket {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	var b bucket
+	require.NoError(t, json.Unmarshal(kv.value, &b))
+	return b
+}
+
+type fakeTxn struct {
+	kv   *fakeKV
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+}
+
+func (t *fakeTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *fakeTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.ops = append(t.ops, ops...)
+	return t
+}
+
+func (t *fakeTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	return t
+}
+
+func (t *fakeTxn) Commit() (*clientv3.TxnResponse, error) {
+	kv := t.kv
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if kv.down {
+		return nil, errEtcdDown
+	}
+	kv.txns++
+	if kv.conflicts > 0 {
+		kv.conflicts--
+		other, _ := json.Marshal(bucket{Tokens: 1, Last: time.Now().UnixNano()})
+		kv.put(other)
+	}
+	for _, cmp := range t.cmps {
+		var got, want int64
+		switch u := cmp.TargetUnion.(type) {
+		case *etcdserverpb.Compare_CreateRevision:
+			got, want = kv.create, u.CreateRevision
+		case *etcdserverpb.Compare_ModRevision:
+			got, want = kv.mod, u.ModRevision
+		}
+		if got != want {
+			return &clientv3.TxnResponse{Succeeded: false}, nil
+		}
+	}
+	for _, op := range t.ops {
+		kv.put(op.ValueBytes())
+	}
+	return &clientv3.TxnResponse{Succeeded: true}, nil
+}
+
+func TestEtcdLimiterReserve(t *testing.T) {
+	kv := &fakeKV{}
+	l := NewEtcdLimiter(kv, "/limit/test", 10, 2)
+	ctx := context.Background()
+
+	// 第一次使用时创建桶
+	require.NoError(t, l.Wait(ctx))
+	assert.Equal(t, int64(1), kv.create)
+	require.NoError(t, l.Wait(ctx))
+	assert.Less(t, kv.bucket(t).Tokens, 0.1)
+
+	// 令牌不足时返回等待时长，不写入etcd
+	txns := kv.txns
+	wait, err := l.reserve(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, wait, 80*time.Millisecond)
+	assert.LessOrEqual(t, wait, 100*time.Millisecond)
+	assert.Equal(t, txns, kv.txns)
+	assert.Less(t, l.Stats().Tokens, 0.1)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond)
+}
+
+func TestEtcdLimiterConflictRetry(t *testing.T) {
+	kv := &fakeKV{conflicts: 2}
+	l := NewEtcdLimiter(kv, "/limit/test", rate.Inf, 1)
+
+	require.NoError(t, l.Wait(context.Background()))
+	// 前两次CAS失败后重新读取并重试
+	assert.Equal(t, 3, kv.txns)
+	assert.Equal(t, 0, kv.conflicts)
+}
+
+func TestEtcdLimiterSharedBudget(t *testing.T) {
+	kv := &fakeKV{}
+	const (
+		limit = 50
+		burst = 5
+		total = 20
+	)
+	workers := []*EtcdLimiter{
+		NewEtcdLimiter(kv, "/limit/shared", limit, burst),
+		NewEtcdLimiter(kv, "/limit/shared", limit, burst),
+		NewEtcdLimiter(kv, "/limit/shared", limit, burst),
+	}
+	var (
+		wg    sync.WaitGroup
+		count int32
+	)
+	start := time.Now()
+	for i := 0; i < total; i++ {
+		wg.Add(1)
+		go func(l *EtcdLimiter) {
+			defer wg.Done()
+			assert.NoError(t, l.Wait(context.Background()))
+			atomic.AddInt32(&count, 1)
+		}(workers[i%len(workers)])
+	}
+	wg.Wait()
+	assert.Equal(t, int32(total), count)
+	// 所有worker合计速率不超过limit，桶中的burst个令牌之外需要等待补充
+	want := time.Duration(float64(total-burst) / limit * float64(time.Second))
+	assert.GreaterOrEqual(t, time.Since(start), want*9/10)
+}
+
+// countLimiter 记录Wait调用次数的本地限流器
+type countLimiter struct {
+	RateLimiter
+	waits int
+}
+
+func (l *countLimiter) Wait(ctx context.Context) error {
+	l.waits++
+	return nil
+}
+
+func TestEtcdLimiterFallback(t *testing.T) {
+	kv := &fakeKV{down: true}
+	fallback := &countLimiter{RateLimiter: NewLimiter(rate.Inf, 1)}
+	l := NewEtcdLimiter(kv, "/limit/test", 10, 1, WithFallback(fallback))
+	require.NoError(t, l.Wait(context.Background()))
+	assert.Equal(t, 1, fallback.waits)
+
+	// 没有设置fallback时返回etcd的错误
+	l = NewEtcdLimiter(kv, "/limit/test", 10, 1)
+	err := l.Wait(context.Background())
+	assert.ErrorIs(t, err, errEtcdDown)
+
+	// etcd恢复后不再使用fallback
+	kv.mu.Lock()
+	kv.down = false
+	kv.mu.Unlock()
+	l = NewEtcdLimiter(kv, "/limit/test", 10, 1, WithFallback(fallback))
+	require.NoError(t, l.Wait(context.Background()))
+	assert.Equal(t, 1, fallback.waits)
+}
@@ -3,6 +3,7 @@ package limiter
 import (
 	"context"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/time/rate"
@@ -11,26 +12,115 @@ import (
 type RateLimiter interface {
 	Wait(ctx context.Context) error
 	Limit() rate.Limit
+	Stats() Stats
+}
+
+// Stats 限流器的运行统计
+type Stats struct {
+	Limit rate.Limit
+	Burst int
+	// Tokens 当前可用的令牌数
+	Tokens float64
+	// Waits 调用Wait的次数
+	Waits int64
+	// WaitTime Wait累计阻塞的时长
+	WaitTime time.Duration
+	// WaitsPerSecond 平均每秒调用Wait的次数
+	WaitsPerSecond float64
+	// Limiters 组合限流器中各限流器的统计
+	Limiters []Stats `json:",omitempty"`
+}
+
+// Bottleneck 返回累计等待时间最长的限流器的统计
+func (s Stats) Bottleneck() Stats {
+	if len(s.Limiters) == 0 {
+		return s
+	}
+	best := s.Limiters[0]
+	for _, l := range s.Limiters[1:] {
+		if l.WaitTime > best.WaitTime {
+			best = l
+		}
+	}
+	return best.Bottleneck()
+}
+
+// waitStats 记录Wait的次数与阻塞时长
+type waitStats struct {
+	start     time.Time
+	waits     atomic.Int64
+	waitNanos atomic.Int64
+}
+
+func newWaitStats() *waitStats {
+	return &waitStats{start: time.Now()}
+}
+
+func (w *waitStats) record(start time.Time) {
+	w.waits.Add(1)
+	w.waitNanos.Add(int64(time.Since(start)))
+}
+
+func (w *waitStats) fill(s *Stats) {
+	s.Waits = w.waits.Load()
+	s.WaitTime = time.Duration(w.waitNanos.Load())
+	if elapsed := time.Since(w.start).Seconds(); elapsed > 0 {
+		s.WaitsPerSecond = float64(s.Waits) / elapsed
+	}
 }
 
 func Per(enventCount int, duration time.Duration) rate.Limit {
 	return rate.Every(duration / time.Duration(enventCount))
 }
 
+// TokenLimiter 本地令牌桶，在rate.Limiter的基础上记录统计
+type TokenLimiter struct {
+	*rate.Limiter
+	stats *waitStats
+}
+
+// NewLimiter 创建令牌桶，burst小于1时为1
+func NewLimiter(limit rate.Limit, burst int) *TokenLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenLimiter{
+		Limiter: rate.NewLimiter(limit, burst),
+		stats:   newWaitStats(),
+	}
+}
+
+func (l *TokenLimiter) Wait(ctx context.Context) error {
+	defer l.stats.record(time.Now())
+	return l.Limiter.Wait(ctx)
+}
+
+func (l *TokenLimiter) Stats() Stats {
+	s := Stats{
+		Limit:  l.Limiter.Limit(),
+		Burst:  l.Limiter.Burst(),
+		Tokens: l.Limiter.Tokens(),
+	}
+	l.stats.fill(&s)
+	return s
+}
+
 func NewMultiLimit(limiters ...RateLimiter) *multiLimiter {
 	byLimit := func(i, j int) bool {
 		return limiters[i].Limit() < limiters[j].Limit()
 	}
 	sort.Slice(limiters, byLimit)
 
-	return &multiLimiter{limiters}
+	return &multiLimiter{limiters: limiters, stats: newWaitStats()}
 }
 
 type multiLimiter struct {
 	limiters []RateLimiter
+	stats    *waitStats
 }
 
 func (l *multiLimiter) Wait(ctx context.Context) error {
+	defer l.stats.record(time.Now())
 	for _, l := range l.limiters {
 		if err := l.Wait(ctx); err != nil {
 			return err
@@ -40,6 +130,40 @@ func (l *multiLimiter) Wait(ctx context.Context) error {
 	return nil
 }
 
+// Limit 各限流器中最小的速率，自适应限流器的速率会变化，每次调用时重新计算
+// 没有限流器时不限速，返回rate.Inf
 func (l *multiLimiter) Limit() rate.Limit {
-	return l.limiters[0].Limit()
+	limit := rate.Inf
+	for _, child := range l.limiters {
+		if child.Limit() < limit {
+			limit = child.Limit()
+		}
+	}
+	return limit
+}
+
+// Stats 速率为最慢的限流器的速率，可用令牌数为各限流器中最少的
+func (l *multiLimiter) Stats() Stats {
+	s := Stats{Limit: l.Limit()}
+	for i, child := range l.limiters {
+		cs := child.Stats()
+		if i == 0 || cs.Tokens < s.Tokens {
+			s.Tokens = cs.Tokens
+		}
+		if i == 0 || cs.Burst < s.Burst {
+			s.Burst = cs.Burst
+		}
+		s.Limiters = append(s.Limiters, cs)
+	}
+	l.stats.fill(&s)
+	return s
+}
+
+// Feedback 将请求结果转发给可调整速率的限流器
+func (l *multiLimiter) Feedback(f Feedback) {
+	for _, l := range l.limiters {
+		if fl, ok := l.(FeedbackLimiter); ok {
+			fl.Feedback(f)
+		}
+	}
 }
@@ -0,0 +1,50 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestMultiLimiterStats(t *testing.T) {
+	fast := NewLimiter(1000, 3)
+	slow := NewLimiter(50, 1)
+	l := NewMultiLimit(fast, slow)
+	ctx := context.Background()
+	for i := 0; i < 4; i++ {
+		require.NoError(t, l.Wait(ctx))
+	}
+
+	s := l.Stats()
+	assert.Equal(t, rate.Limit(50), s.Limit)
+	assert.Equal(t, 1, s.Burst)
+	assert.Equal(t, int64(4), s.Waits)
+	require.Len(t, s.Limiters, 2)
+	assert.Equal(t, 1, s.Limiters[0].Burst)
+	assert.Equal(t, 3, s.Limiters[1].Burst)
+	// 速率低的限流器等待时间更长
+	assert.Equal(t, rate.Limit(50), s.Bottleneck().Limit)
+	assert.Greater(t, s.Bottleneck().WaitTime, s.Limiters[1].WaitTime)
+	assert.Equal(t, 1, NewLimiter(1, 0).Burst())
+}
+
+func TestMultiLimiterLimit(t *testing.T) {
+	adaptive := NewAdaptiveLimiter(1, 16, WithBackoffInterval(0))
+	l := NewMultiLimit(NewLimiter(8, 1), adaptive)
+	assert.Equal(t, rate.Limit(8), l.Limit())
+
+	// 自适应限流器降速后成为最慢的限流器
+	l.Feedback(Feedback{StatusCode: 503})
+	l.Feedback(Feedback{StatusCode: 503})
+	assert.Equal(t, rate.Limit(4), l.Limit())
+	assert.Equal(t, rate.Limit(4), l.Stats().Limit)
+
+	// 没有限流器时不限速
+	empty := NewMultiLimit()
+	assert.Equal(t, rate.Inf, empty.Limit())
+	assert.Equal(t, rate.Inf, empty.Stats().Limit)
+	assert.NoError(t, empty.Wait(context.Background()))
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/awaketai/crawler/cmd"
@@ -33,9 +39,9 @@ import (
 	"go-micro.dev/v4/config/source"
 	"go-micro.dev/v4/config/source/file"
 	"go-micro.dev/v4/registry"
+	clientv3 "go.etcd.io/etcd/client/v3"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 )
 
@@ -77,9 +83,14 @@ func main5() {
 		panic(err)
 	}
 	logger.Sugar().Debugf("serverCfg:%+v", serverCfg)
-	multiWorkDouban(cfg, logger)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	crawlerDone := multiWorkDouban(ctx, cfg, logger)
 
 	RunGRPCServer(logger, cfg)
+	// 等待爬虫处理完进行中的请求并刷新存储后退出
+	stop()
+	<-crawlerDone
 }
 
 type ServerConfig struct {
@@ -93,44 +104,137 @@ type ServerConfig struct {
 	ClientTimeOut     int
 }
 
-func multiWorkDouban(cfg config.Config, logger *zap.Logger) {
-	fetcher := getFetcher(cfg, logger)
+func multiWorkDouban(ctx context.Context, cfg config.Config, logger *zap.Logger) <-chan struct{} {
+	fetcher := getFetcher(ctx, cfg, logger)
 	storage := getStorage(cfg, logger)
-	tasks, err := getSeeds(cfg, logger, fetcher, storage)
+	proxyGroups, err := getProxyGroups(ctx, cfg)
+	if err != nil {
+		panic("get proxy groups err:" + err.Error())
+	}
+	tasks, err := getSeeds(cfg, logger, fetcher, storage, proxyGroups)
 	if err != nil {
 		panic("get seeds err:" + err.Error())
 	}
 
-	s := engine.NewCrawler(
+	crawlerOpts := []engine.Option{
 		engine.WithFetcher(fetcher),
 		engine.WithLogger(logger),
 		engine.WithTasks(tasks),
 		engine.WithWorkCount(5),
-		engine.WithScheduler(engine.NewSchedule()),
-	)
-	go s.Run()
+	}
+	scheduleOpts := []engine.ScheduleOption{
+		engine.WithScheduleLogger(logger),
+	}
+	// 配置检查点目录后，队列与已访问集合会持久化，重启后从中断处继续
+	var store *engine.FileQueueStore
+	if dir := cfg.Get("checkpoint", "dir").String(""); dir != "" {
+		store, err = engine.NewFileQueueStore(filepath.Join(dir, "queue.jsonl"))
+		if err != nil {
+			panic("create queue store err:" + err.Error())
+		}
+		scheduleOpts = append(scheduleOpts, engine.WithQueueStore(store))
+		crawlerOpts = append(crawlerOpts, engine.WithCheckpoint(filepath.Join(dir, "visited.json"), time.Minute))
+	}
+	crawlerOpts = append(crawlerOpts, engine.WithScheduler(engine.NewSchedule(scheduleOpts...)))
+	s := engine.NewCrawler(crawlerOpts...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+		if store != nil {
+			store.Close()
+		}
+	}()
+
+	return done
 }
 
-func getProxy(cfg config.Config) (proxy.ProxyFunc, error) {
-	proxyURLs := cfg.Get("fetcher", "proxy").StringSlice([]string{})
-	p, err := proxy.RoundRobinProxySwitcher(proxyURLs...)
+// getProxy 根据配置创建代理池，proxy_check_url不为空时定期检查代理是否可用
+// source不为空时从文件或HTTP接口定期刷新代理列表
+func getProxy(ctx context.Context, cfg config.Config, proxyURLs []string, source string) (*proxy.Pool, error) {
+	opts := []proxy.Option{
+		proxy.WithCheckURL(cfg.Get("fetcher", "proxy_check_url").String("")),
+		proxy.WithCheckInterval(time.Duration(cfg.Get("fetcher", "proxy_check_interval").Int(60)) * time.Second),
+		proxy.WithMaxFailures(cfg.Get("fetcher", "proxy_max_failures").Int(3)),
+		proxy.WithCoolDown(time.Duration(cfg.Get("fetcher", "proxy_cool_down").Int(300)) * time.Second),
+		proxy.WithRefreshInterval(time.Duration(cfg.Get("fetcher", "proxy_refresh_interval").Int(300)) * time.Second),
+	}
+	if source != "" {
+		opts = append(opts, proxy.WithSource(proxy.NewSource(source)))
+	}
+	pool, err := proxy.NewPool(proxyURLs, opts...)
 	if err != nil {
-		panic("RoundRobinProxySwitcher err:" + err.Error())
+		return nil, err
 	}
+	go pool.Run(ctx)
 
-	return p, err
+	return pool, nil
 }
 
-func getFetcher(cfg config.Config, logger *zap.Logger) collect.Fetcher {
-	p, err := getProxy(cfg)
+// getProxyGroups 按[proxy_groups]与[proxy_sources]创建各代理组的代理池，任务通过proxy_group选择
+func getProxyGroups(ctx context.Context, cfg config.Config) (map[string]*proxy.Pool, error) {
+	groups := map[string][]string{}
+	if err := cfg.Get("proxy_groups").Scan(&groups); err != nil {
+		return nil, err
+	}
+	sources := map[string]string{}
+	if err := cfg.Get("proxy_sources").Scan(&sources); err != nil {
+		return nil, err
+	}
+	for name := range sources {
+		if _, ok := groups[name]; !ok {
+			groups[name] = nil
+		}
+	}
+	pools := make(map[string]*proxy.Pool, len(groups))
+	for name, urls := range groups {
+		pool, err := getProxy(ctx, cfg, urls, sources[name])
+		if err != nil {
+			return nil, fmt.Errorf("proxy group %s err:%w", name, err)
+		}
+		pools[name] = pool
+	}
+
+	return pools, nil
+}
+
+func getFetcher(ctx context.Context, cfg config.Config, logger *zap.Logger) collect.Fetcher {
+	// 回放时不发出网络请求，不需要创建代理池
+	if path := cfg.Get("fetcher", "replay").String(""); path != "" {
+		replay, err := collect.NewReplayFetcher(path)
+		if err != nil {
+			panic("create replay fetcher failed:" + err.Error())
+		}
+		return replay
+	}
+	p, err := getProxy(ctx, cfg, cfg.Get("fetcher", "proxy").StringSlice([]string{}), cfg.Get("fetcher", "proxy_source").String(""))
 	if err != nil {
 		panic("getProxy err:" + err.Error())
 	}
 	timeout := cfg.Get("fetcher", "timeout").Int(3000)
-	fetcher := &collect.BrowserFetch{
-		Timeout: time.Duration(timeout) * time.Millisecond,
-		Logger:  logger,
-		Proxy:   p,
+	var transport collect.TransportConfig
+	if err := cfg.Get("fetcher", "transport").Scan(&transport); err != nil {
+		panic("get fetcher transport err:" + err.Error())
+	}
+	var fetcher collect.Fetcher = collect.NewBrowserFetch(
+		collect.WithFetchTimeout(time.Duration(timeout)*time.Millisecond),
+		collect.WithFetchLogger(logger),
+		collect.WithFetchProxyPool(p),
+		collect.WithTransport(transport),
+	)
+	if dir := cfg.Get("fetcher", "cache_dir").String(""); dir != "" {
+		fetcher = &collect.CacheFetcher{
+			Fetcher: fetcher,
+			Dir:     dir,
+			Offline: cfg.Get("fetcher", "offline").Bool(false),
+		}
+	}
+	if path := cfg.Get("fetcher", "record").String(""); path != "" {
+		recorder, err := collect.NewRecordingFetcher(fetcher, path)
+		if err != nil {
+			panic("create recording fetcher failed:" + err.Error())
+		}
+		fetcher = recorder
 	}
 
 	return fetcher
@@ -229,14 +333,66 @@ func reqGRPC(cfg config.Config) {
 	fmt.Println("grpc resp:", rsp.Greeting)
 }
 
-func getSeeds(cfg config.Config, logger *zap.Logger, fetcher collect.Fetcher, storage collector.Storager) ([]*collect.Task, error) {
+var (
+	etcdOnce   sync.Once
+	etcdClient *clientv3.Client
+	etcdErr    error
+)
+
+// getEtcdClient 分布式限速使用的etcd客户端，与注册中心使用同一etcd
+func getEtcdClient(cfg config.Config) (*clientv3.Client, error) {
+	etcdOnce.Do(func() {
+		etcdClient, etcdErr = clientv3.New(clientv3.Config{
+			Endpoints:   []string{cfg.Get("RegistryAddress").String(":2379")},
+			DialTimeout: 5 * time.Second,
+		})
+	})
+	return etcdClient, etcdErr
+}
+
+// getLimiter 根据配置创建限流器，name用于区分分布式限流在etcd中的key
+// 配置为空时返回错误，避免规则的空配置代替任务的限流器后不再限速
+func getLimiter(cfg config.Config, name string, lcfg []collect.LimitConfig) (limiter.RateLimiter, error) {
+	if len(lcfg) == 0 {
+		return nil, errors.New("empty limit config")
+	}
+	var limits []limiter.RateLimiter
+	for i, l := range lcfg {
+		r := limiter.Per(l.EventCount, time.Duration(l.EventDur)*time.Second)
+		switch {
+		case l.Adaptive:
+			limits = append(limits, limiter.NewAdaptiveLimiter(r/10, r, limiter.WithBurst(l.Bucket)))
+		case l.Distributed:
+			cli, err := getEtcdClient(cfg)
+			if err != nil {
+				return nil, err
+			}
+			key := fmt.Sprintf("/resources/limiter/%s/%d", name, i)
+			limits = append(limits, limiter.NewEtcdLimiter(cli, key, r, l.Bucket, limiter.WithFallback(limiter.NewLimiter(r, l.Bucket))))
+		default:
+			limits = append(limits, limiter.NewLimiter(r, l.Bucket))
+		}
+	}
+
+	return limiter.NewMultiLimit(limits...), nil
+}
+
+func getSeeds(cfg config.Config, logger *zap.Logger, fetcher collect.Fetcher, storage collector.Storager, proxyGroups map[string]*proxy.Pool) ([]*collect.Task, error) {
 	var tcfg []collect.Options
 	if err := cfg.Get("Tasks").Scan(&tcfg); err != nil {
 		logger.Error("get tasks err", zap.Error(err))
 		return nil, err
 	}
+	// wait_time为0表示不等待，需要与未设置区分，未设置时使用任务默认值
+	var waitTimes []struct {
+		WaitTime *int64 `json:"wait_time"`
+	}
+	if err := cfg.Get("Tasks").Scan(&waitTimes); err != nil {
+		logger.Error("get tasks err", zap.Error(err))
+		return nil, err
+	}
 	tasks := make([]*collect.Task, 0, len(tcfg))
-	for _, v := range tcfg {
+	for i, v := range tcfg {
 		t := collect.NewTask(
 			collect.WithCookie(v.Cookie),
 			collect.WithFetcher(fetcher),
@@ -245,21 +401,53 @@ func getSeeds(cfg config.Config, logger *zap.Logger, fetcher collect.Fetcher, st
 			collect.WithReload(v.Reload),
 			collect.WithStorage(storage),
 			collect.WithUrl(v.Url),
+			collect.WithRetry(v.Retry),
+			collect.WithCron(v.Cron),
+			collect.WithChangedOnly(v.ChangedOnly),
+			collect.WithAccounts(v.Accounts...),
+			collect.WithSessionFile(v.SessionFile),
+			collect.WithIgnoreRobots(v.IgnoreRobots),
+			collect.WithStickyProxy(v.StickyProxy),
 		)
-		if v.WaitTime > 0 {
-			t.WaitTime = v.WaitTime
+		if v.ProxyGroup != "" {
+			pool, ok := proxyGroups[v.ProxyGroup]
+			if !ok {
+				return nil, fmt.Errorf("task %s proxy group not found:%s", v.Name, v.ProxyGroup)
+			}
+			t.Proxy = pool
+		}
+		if wt := waitTimes[i].WaitTime; wt != nil && *wt >= 0 {
+			t.WaitTime = *wt
 		}
 		if v.MaxDepth > 0 {
 			t.MaxDepth = v.MaxDepth
 		}
-		var limits []limiter.RateLimiter
+		if v.BanCoolDown > 0 {
+			t.BanCoolDown = v.BanCoolDown
+		}
+		for _, vc := range v.ValidatorCfg {
+			validator, err := vc.Validator()
+			if err != nil {
+				return nil, fmt.Errorf("task %s validator err:%w", v.Name, err)
+			}
+			t.Validators = append(t.Validators, validator)
+		}
 		if len(v.LimitCfg) > 0 {
-			for _, l := range v.LimitCfg {
-				lm := rate.NewLimiter(limiter.Per(l.EventCount, time.Duration(l.EventDur)*time.Second), 1)
-				limits = append(limits, lm)
+			lm, err := getLimiter(cfg, v.Name, v.LimitCfg)
+			if err != nil {
+				return nil, fmt.Errorf("task %s limit err:%w", v.Name, err)
+			}
+			t.Limit = lm
+		}
+		for rule, lc := range v.RuleLimitCfg {
+			lm, err := getLimiter(cfg, v.Name+"/"+rule, lc)
+			if err != nil {
+				return nil, fmt.Errorf("task %s rule %s limit err:%w", v.Name, rule, err)
+			}
+			if t.RuleLimits == nil {
+				t.RuleLimits = map[string]limiter.RateLimiter{}
 			}
-			multiLimiter := limiter.NewMultiLimit(limits...)
-			t.Limit = multiLimiter
+			t.RuleLimits[rule] = lm
 		}
 		switch v.FetchType {
 		case collect.BrowserFetchType:
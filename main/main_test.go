@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/awaketai/crawler/collect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-micro.dev/v4/config"
+	"go-micro.dev/v4/config/source/memory"
+	"go.uber.org/zap"
 )
 
 func TestSlice(t *testing.T) {
@@ -55,3 +65,49 @@ func TestMain(t *testing.T) {
 
 	}
 }
+
+func TestGetSeedsWaitTime(t *testing.T) {
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+	require.NoError(t, cfg.Load(memory.NewSource(memory.WithJSON([]byte(`{
+		"Tasks": [
+			{"name": "default_wait"},
+			{"name": "no_wait", "wait_time": 0},
+			{"name": "long_wait", "wait_time": 10}
+		]
+	}`)))))
+
+	tasks, err := getSeeds(cfg, zap.NewNop(), nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+	assert.Equal(t, int64(5), tasks[0].WaitTime)
+	assert.Equal(t, int64(0), tasks[1].WaitTime)
+	assert.Equal(t, int64(10), tasks[2].WaitTime)
+}
+
+func TestGetFetcherReplaySkipsProxy(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+	require.NoError(t, os.WriteFile(cassette, nil, 0o644))
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+	// 代理地址无效，回放时不应创建代理池
+	require.NoError(t, cfg.Load(memory.NewSource(memory.WithJSON([]byte(fmt.Sprintf(`{
+		"fetcher": {"proxy": ["://bad"], "replay": %q}
+	}`, cassette))))))
+
+	fetcher := getFetcher(context.Background(), cfg, zap.NewNop())
+	assert.IsType(t, &collect.ReplayFetcher{}, fetcher)
+}
+
+func TestGetSeedsEmptyRuleLimits(t *testing.T) {
+	cfg, err := config.NewConfig()
+	require.NoError(t, err)
+	require.NoError(t, cfg.Load(memory.NewSource(memory.WithJSON([]byte(`{
+		"Tasks": [
+			{"name": "empty_rule_limit", "rule_limits": {"detail": []}}
+		]
+	}`)))))
+
+	_, err = getSeeds(cfg, zap.NewNop(), nil, nil, nil)
+	assert.ErrorContains(t, err, "empty limit config")
+}
@@ -1,7 +1,6 @@
 package doubangroup
 
 import (
-	"os"
 	"regexp"
 	"strconv"
 
@@ -10,25 +9,19 @@ import (
 
 var DoubanBookTask = &collect.Task{
 	Options: collect.Options{
-		Name:     "douban_book_list",
-		WaitTime: 1,
-		MaxDepth: 5,
-		Cookie:   cookie,
+		Name:       "douban_book_list",
+		WaitTime:   1,
+		MaxDepth:   5,
+		Validators: doubanValidators,
 	},
 	Rule: collect.RuleTree{
 		Root: func() ([]*collect.Request, error) {
-			content, err := os.ReadFile("testhtml/index.html")
-			if err != nil {
-				return nil, err
-			}
 			roots := []*collect.Request{
 				{
 					Priority: 1,
 					Url:      "https://book.douban.com",
 					Method:   "GET",
 					RuleName: "数据tag",
-					TestBody: content,
-					Test:     false,
 				},
 			}
 			return roots, nil
@@ -66,10 +59,6 @@ func parseTag(ctx *collect.CrawlerContext) (collect.ParseResult, error) {
 	re := regexp.MustCompile(regexpStr)
 	matches := re.FindAllSubmatch(ctx.Body, -1)
 	result := collect.ParseResult{}
-	tagListContent, err := os.ReadFile("testhtml/fiction_tag.html")
-	if err != nil {
-		return result, err
-	}
 	for _, m := range matches {
 		result.Requests = append(result.Requests, &collect.Request{
 			Url:      "https://book.douban.com" + string(m[1]),
@@ -77,8 +66,6 @@ func parseTag(ctx *collect.CrawlerContext) (collect.ParseResult, error) {
 			Method:   "GET",
 			RuleName: "书籍列表",
 			Task:     ctx.Req.Task,
-			TestBody: tagListContent,
-			Test:     false,
 		})
 	}
 	// 减少抓取数量，防止被封
@@ -96,10 +83,6 @@ func parseBookList(ctx *collect.CrawlerContext) (collect.ParseResult, error) {
 	re := regexp.MustCompile(BooklistRe)
 	matches := re.FindAllSubmatch(ctx.Body, -1)
 	result := collect.ParseResult{}
-	detailContent, err := os.ReadFile("testhtml/book_detail.html")
-	if err != nil {
-		return result, err
-	}
 	for _, m := range matches {
 		req := &collect.Request{
 			Method:   "GET",
@@ -107,7 +90,6 @@ func parseBookList(ctx *collect.CrawlerContext) (collect.ParseResult, error) {
 			Url:      string(m[1]),
 			Depth:    ctx.Req.Depth + 1,
 			RuleName: "书籍简介",
-			TestBody: detailContent,
 		}
 		req.TmpData = &collect.Tmp{}
 		req.TmpData.Set("book_name", string(m[2]))
@@ -9,10 +9,10 @@ import (
 
 var DouBanGroupTask = &collect.Task{
 	Options: collect.Options{
-		Name:     "find_douban_sun_room",
-		WaitTime: 1,
-		MaxDepth: 5,
-		Cookie:   cookie,
+		Name:       "find_douban_sun_room",
+		WaitTime:   1,
+		MaxDepth:   5,
+		Validators: doubanValidators,
 	},
 	Rule: collect.RuleTree{
 		Root: func() ([]*collect.Request, error) {
@@ -36,6 +36,12 @@ var DouBanGroupTask = &collect.Task{
 	},
 }
 
+// doubanValidators 豆瓣返回的机器人验证页视为封禁，内容过短的页面通常为登录或异常页面
+var doubanValidators = []collect.Validator{
+	collect.ContainsValidator("你访问豆瓣的方式有点像机器人程序", collect.Ban),
+	collect.MinSizeValidator(6000, collect.SoftFailure),
+}
+
 const cityListRe = `href="(https://www.douban.com/group/topic/[0-9a-zA-Z]+/)"[^>]*>([^<]+)</a>`
 const ContentRe = `<div class="topic-content">[\s\S]*?阳台[\s\S]*?<div`
 
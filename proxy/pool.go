@@ -0,0 +1,409 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrNoProxy 代理池中没有可用的代理
+var ErrNoProxy = errors.New("no available proxy")
+
+type proxyCtxKey struct{}
+
+// WithProxyURL 在ctx中携带本次请求使用的代理，代理池的ProxyFunc优先使用该代理
+func WithProxyURL(ctx context.Context, u *url.URL) context.Context {
+	return context.WithValue(ctx, proxyCtxKey{}, u)
+}
+
+// FromContext 返回ctx中携带的代理
+func FromContext(ctx context.Context) *url.URL {
+	u, _ := ctx.Value(proxyCtxKey{}).(*url.URL)
+	return u
+}
+
+// Proxy 代理池中的单个代理及其统计
+type Proxy struct {
+	URL       *url.URL
+	Successes int64
+	Failures  int64
+	// Latency 请求延迟的指数加权移动平均
+	Latency time.Duration
+	// EvictedUntil 连续失败后被剔除，在此时间之前不会被选择
+	EvictedUntil time.Time
+	// consecutive 连续失败次数
+	consecutive int
+}
+
+// weight 成功率越高、延迟越低的代理权重越大
+func (p *Proxy) weight() float64 {
+	rate := float64(p.Successes+1) / float64(p.Successes+p.Failures+2)
+	return rate / (p.Latency + 100*time.Millisecond).Seconds()
+}
+
+type options struct {
+	// checkURL 健康检查访问的地址，为空时不检查
+	checkURL      string
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	// maxFailures 连续失败多少次后剔除
+	maxFailures int
+	coolDown    time.Duration
+	// source 动态代理列表，每隔refreshInterval刷新一次
+	source          Source
+	refreshInterval time.Duration
+}
+
+var defaultOptions = options{
+	checkInterval:   time.Minute,
+	checkTimeout:    10 * time.Second,
+	maxFailures:     3,
+	coolDown:        5 * time.Minute,
+	refreshInterval: 5 * time.Minute,
+}
+
+type Option func(opts *options)
+
+// WithCheckURL 健康检查通过代理访问的地址，返回2xx表示代理可用
+func WithCheckURL(u string) Option {
+	return func(opts *options) {
+		opts.checkURL = u
+	}
+}
+
+func WithCheckInterval(d time.Duration) Option {
+	return func(opts *options) {
+		opts.checkInterval = d
+	}
+}
+
+func WithCheckTimeout(d time.Duration) Option {
+	return func(opts *options) {
+		opts.checkTimeout = d
+	}
+}
+
+func WithMaxFailures(n int) Option {
+	return func(opts *options) {
+		opts.maxFailures = n
+	}
+}
+
+// WithCoolDown 代理被剔除的时长，期间健康检查通过会提前恢复
+func WithCoolDown(d time.Duration) Option {
+	return func(opts *options) {
+		opts.coolDown = d
+	}
+}
+
+// WithSource 从source加载代理，与NewPool传入的代理合并
+func WithSource(source Source) Option {
+	return func(opts *options) {
+		opts.source = source
+	}
+}
+
+func WithRefreshInterval(d time.Duration) Option {
+	return func(opts *options) {
+		opts.refreshInterval = d
+	}
+}
+
+// Pool 代理池，记录每个代理的成功、失败与延迟
+// 连续失败的代理被剔除一段时间，按成功率与延迟加权随机选择代理
+type Pool struct {
+	mu      sync.Mutex
+	proxies []*Proxy
+	// sticky 键绑定的代理，代理被剔除前同一键始终使用同一代理
+	sticky map[string]*url.URL
+	// static 创建时传入的代理，刷新时保留
+	static []*url.URL
+	options
+}
+
+// NewPool 创建代理池，代理地址格式见ParseURL，设置了WithSource时先加载一次代理列表
+func NewPool(proxyURLs []string, opts ...Option) (*Pool, error) {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(proxyURLs) < 1 && options.source == nil {
+		return nil, fmt.Errorf("proxy URL list empty")
+	}
+	p := &Pool{
+		sticky:  map[string]*url.URL{},
+		options: options,
+	}
+	for _, raw := range proxyURLs {
+		u, err := ParseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		p.static = append(p.static, u)
+	}
+	p.update(p.static)
+	if p.source != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), p.checkTimeout)
+		defer cancel()
+		if err := p.Refresh(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Refresh 从source重新加载代理，已有代理的统计保留，不在列表中的代理被移除
+// 列表中无法解析的代理被忽略
+func (p *Pool) Refresh(ctx context.Context) error {
+	if p.source == nil {
+		return nil
+	}
+	list, err := p.source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load proxy list:%w", err)
+	}
+	urls := append([]*url.URL{}, p.static...)
+	for _, raw := range list {
+		u, err := ParseURL(raw)
+		if err != nil {
+			continue
+		}
+		urls = append(urls, u)
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("proxy URL list empty")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.update(urls)
+	return nil
+}
+
+// update 替换代理列表，调用方持有锁
+func (p *Pool) update(urls []*url.URL) {
+	old := make(map[string]*Proxy, len(p.proxies))
+	for _, px := range p.proxies {
+		old[px.URL.String()] = px
+	}
+	proxies := make([]*Proxy, 0, len(urls))
+	seen := map[string]bool{}
+	for _, u := range urls {
+		key := u.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if px, ok := old[key]; ok {
+			proxies = append(proxies, px)
+			continue
+		}
+		proxies = append(proxies, &Proxy{URL: u})
+	}
+	p.proxies = proxies
+	for key, u := range p.sticky {
+		if !seen[u.String()] {
+			delete(p.sticky, key)
+		}
+	}
+}
+
+// Pick 从未被剔除的代理中按权重随机选择一个
+func (p *Pool) Pick() (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pick()
+}
+
+// PickFor 为key选择代理，key已绑定且代理未被剔除时返回同一代理，key为空时同Pick
+// 用于同一登录会话、站点或任务保持相同的出口IP
+func (p *Pool) PickFor(key string) (*url.URL, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key == "" {
+		return p.pick()
+	}
+	if u, ok := p.sticky[key]; ok {
+		if px := p.find(u); px != nil && !time.Now().Before(px.EvictedUntil) {
+			return u, nil
+		}
+	}
+	u, err := p.pick()
+	if err != nil {
+		return nil, err
+	}
+	p.sticky[key] = u
+	return u, nil
+}
+
+// Unbind 解除key绑定的代理，下次PickFor重新选择，用于代理被目标站点封禁时
+func (p *Pool) Unbind(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sticky, key)
+}
+
+func (p *Pool) pick() (*url.URL, error) {
+	now := time.Now()
+	var (
+		candidates []*Proxy
+		total      float64
+	)
+	for _, px := range p.proxies {
+		if now.Before(px.EvictedUntil) {
+			continue
+		}
+		candidates = append(candidates, px)
+		total += px.weight()
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoProxy
+	}
+	r := rand.Float64() * total
+	for _, px := range candidates {
+		r -= px.weight()
+		if r < 0 {
+			return px.URL, nil
+		}
+	}
+	return candidates[len(candidates)-1].URL, nil
+}
+
+// ProxyFunc 用于http.Transport.Proxy，请求ctx中携带了代理时使用该代理
+func (p *Pool) ProxyFunc(req *http.Request) (*url.URL, error) {
+	if u := FromContext(req.Context()); u != nil {
+		return u, nil
+	}
+	return p.Pick()
+}
+
+// Report 记录一次通过代理u的请求结果
+func (p *Pool) Report(u *url.URL, err error, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	px := p.find(u)
+	if px == nil {
+		return
+	}
+	if err != nil {
+		px.Failures++
+		px.consecutive++
+		if px.consecutive >= p.maxFailures {
+			px.EvictedUntil = time.Now().Add(p.coolDown)
+		}
+		return
+	}
+	px.Successes++
+	px.consecutive = 0
+	px.EvictedUntil = time.Time{}
+	if px.Latency == 0 {
+		px.Latency = latency
+	} else {
+		px.Latency = (px.Latency*7 + latency) / 8
+	}
+}
+
+func (p *Pool) find(u *url.URL) *Proxy {
+	if u == nil {
+		return nil
+	}
+	for _, px := range p.proxies {
+		if px.URL.String() == u.String() {
+			return px
+		}
+	}
+	return nil
+}
+
+// Proxies 返回所有代理统计的副本
+func (p *Pool) Proxies() []Proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]Proxy, 0, len(p.proxies))
+	for _, px := range p.proxies {
+		list = append(list, *px)
+	}
+	return list
+}
+
+// Run 定期刷新代理列表并对所有代理做健康检查，ctx取消后返回
+// 没有配置检查地址与代理来源时直接返回
+func (p *Pool) Run(ctx context.Context) {
+	if p.checkURL == "" && p.source == nil {
+		return
+	}
+	var check, refresh <-chan time.Time
+	if p.checkURL != "" {
+		ticker := time.NewTicker(p.checkInterval)
+		defer ticker.Stop()
+		check = ticker.C
+		p.Check(ctx)
+	}
+	if p.source != nil {
+		ticker := time.NewTicker(p.refreshInterval)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-check:
+			p.Check(ctx)
+		case <-refresh:
+			// 刷新失败时继续使用原来的代理
+			_ = p.Refresh(ctx)
+		}
+	}
+}
+
+// Check 并发检查所有代理，包括被剔除的代理
+func (p *Pool) Check(ctx context.Context) {
+	p.mu.Lock()
+	urls := make([]*url.URL, 0, len(p.proxies))
+	for _, px := range p.proxies {
+		urls = append(urls, px.URL)
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, u := range urls {
+		wg.Add(1)
+		go func(u *url.URL) {
+			defer wg.Done()
+			start := time.Now()
+			err := p.check(ctx, u)
+			if ctx.Err() != nil {
+				return
+			}
+			p.Report(u, err, time.Since(start))
+		}(u)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) check(ctx context.Context, u *url.URL) error {
+	ctx, cancel := context.WithTimeout(ctx, p.checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.checkURL, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(u)},
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("health check status:%s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolEviction(t *testing.T) {
+	pool, err := NewPool([]string{"http://a:1", "http://b:1"}, WithMaxFailures(2), WithCoolDown(time.Hour))
+	require.NoError(t, err)
+	a, b := pool.proxies[0].URL, pool.proxies[1].URL
+
+	pool.Report(a, errors.New("connection refused"), 0)
+	pool.Report(a, errors.New("connection refused"), 0)
+	for i := 0; i < 20; i++ {
+		u, err := pool.Pick()
+		require.NoError(t, err)
+		assert.Equal(t, b, u)
+	}
+
+	pool.Report(b, errors.New("timeout"), 0)
+	pool.Report(b, errors.New("timeout"), 0)
+	_, err = pool.Pick()
+	assert.ErrorIs(t, err, ErrNoProxy)
+
+	// 成功后恢复
+	pool.Report(a, nil, time.Millisecond)
+	u, err := pool.Pick()
+	require.NoError(t, err)
+	assert.Equal(t, a, u)
+}
+
+func TestPoolWeight(t *testing.T) {
+	pool, err := NewPool([]string{"http://fast:1", "http://slow:1"})
+	require.NoError(t, err)
+	fast, slow := pool.proxies[0].URL, pool.proxies[1].URL
+	for i := 0; i < 10; i++ {
+		pool.Report(fast, nil, 10*time.Millisecond)
+		pool.Report(slow, nil, 2*time.Second)
+	}
+	picked := 0
+	for i := 0; i < 1000; i++ {
+		if u, _ := pool.Pick(); u == fast {
+			picked++
+		}
+	}
+	assert.Greater(t, picked, 800)
+}
+
+func TestPoolCheck(t *testing.T) {
+	// 普通HTTP代理收到的是完整URL的请求，测试服务器直接返回200即可
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer alive.Close()
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	dead.Close()
+
+	pool, err := NewPool([]string{alive.URL, dead.URL}, WithCheckURL("http://example.com/"), WithMaxFailures(1))
+	require.NoError(t, err)
+	pool.Check(context.Background())
+
+	proxies := pool.Proxies()
+	assert.Equal(t, int64(1), proxies[0].Successes)
+	assert.True(t, proxies[0].EvictedUntil.IsZero())
+	assert.Equal(t, int64(1), proxies[1].Failures)
+	assert.False(t, proxies[1].EvictedUntil.IsZero())
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	u, err := pool.ProxyFunc(req.WithContext(WithProxyURL(context.Background(), pool.proxies[1].URL)))
+	require.NoError(t, err)
+	assert.Equal(t, dead.URL, u.String())
+}
+
+func TestPoolPickFor(t *testing.T) {
+	pool, err := NewPool([]string{"http://a:1", "http://b:1", "http://c:1"}, WithMaxFailures(1), WithCoolDown(time.Hour))
+	require.NoError(t, err)
+	first, err := pool.PickFor("session")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		u, err := pool.PickFor("session")
+		require.NoError(t, err)
+		assert.Equal(t, first, u)
+	}
+
+	// 绑定的代理被剔除后重新选择并绑定
+	pool.Report(first, errors.New("connection reset"), 0)
+	next, err := pool.PickFor("session")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, next)
+	u, _ := pool.PickFor("session")
+	assert.Equal(t, next, u)
+}
+
+func TestPoolUnbind(t *testing.T) {
+	pool, err := NewPool([]string{"http://a:1", "http://b:1"})
+	require.NoError(t, err)
+	_, err = pool.PickFor("session")
+	require.NoError(t, err)
+	assert.Contains(t, pool.sticky, "session")
+
+	pool.Unbind("session")
+	assert.NotContains(t, pool.sticky, "session")
+	_, err = pool.PickFor("session")
+	require.NoError(t, err)
+	assert.Contains(t, pool.sticky, "session")
+}
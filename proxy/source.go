@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Source 代理列表的来源，代理池定期从中刷新代理
+type Source interface {
+	Load(ctx context.Context) ([]string, error)
+}
+
+// FileSource 从文件读取代理列表
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Load(_ context.Context) ([]string, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	return parseList(b)
+}
+
+// HTTPSource 从HTTP接口获取代理列表，如代理商提供的动态代理接口
+type HTTPSource struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+func (s HTTPSource) Load(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("load proxy list status:%s", resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseList(b)
+}
+
+// NewSource 根据配置创建代理来源，http(s)://开头为HTTPSource，否则为文件路径，可带file://前缀
+func NewSource(spec string) Source {
+	if strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://") {
+		return HTTPSource{URL: spec}
+	}
+	return FileSource{Path: strings.TrimPrefix(spec, "file://")}
+}
+
+// parseList 解析代理列表，支持JSON字符串数组或每行一个代理，忽略空行与#开头的注释
+func parseList(b []byte) ([]string, error) {
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		var list []string
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	var list []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list = append(list, line)
+	}
+	return list, scanner.Err()
+}
@@ -0,0 +1,205 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// FetchFunc 获取robots.txt，返回状态码与内容
+type FetchFunc func(ctx context.Context, url string) (int, []byte, error)
+
+type fetchCtxKey struct{}
+
+// NewContext 在ctx中携带获取robots.txt的FetchFunc，优先于WithFetch设置的FetchFunc
+// 用于通过任务的Fetcher获取，与任务的请求使用相同的代理与传输配置
+func NewContext(ctx context.Context, fetch FetchFunc) context.Context {
+	return context.WithValue(ctx, fetchCtxKey{}, fetch)
+}
+
+func fetchFromContext(ctx context.Context) FetchFunc {
+	fetch, _ := ctx.Value(fetchCtxKey{}).(FetchFunc)
+	return fetch
+}
+
+// UnavailableError robots.txt暂时无法获取，在Until之前不会重新获取
+type UnavailableError struct {
+	Until time.Time
+	Err   error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("robots.txt unavailable until %s:%v", e.Until.Format(time.RFC3339), e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+type options struct {
+	userAgent string
+	ttl       time.Duration
+	// errorTTL robots.txt无法获取时推迟访问的时长
+	errorTTL time.Duration
+	fetch    FetchFunc
+}
+
+var defaultOptions = options{
+	userAgent: "*",
+	ttl:       24 * time.Hour,
+	errorTTL:  time.Minute,
+}
+
+type Option func(opts *options)
+
+// WithUserAgent 匹配规则组使用的User-agent，同时作为获取robots.txt时的请求头
+func WithUserAgent(agent string) Option {
+	return func(opts *options) {
+		opts.userAgent = agent
+	}
+}
+
+func WithTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.ttl = ttl
+	}
+}
+
+func WithErrorTTL(ttl time.Duration) Option {
+	return func(opts *options) {
+		opts.errorTTL = ttl
+	}
+}
+
+func WithFetch(fetch FetchFunc) Option {
+	return func(opts *options) {
+		opts.fetch = fetch
+	}
+}
+
+// Cache 按站点缓存robots.txt
+// 状态码为4xx时视为没有限制，5xx或请求失败时在errorTTL内返回*UnavailableError，由调用方推迟访问该站点
+type Cache struct {
+	mu    sync.Mutex
+	hosts map[string]*entry
+	options
+}
+
+type entry struct {
+	// ready 获取完成后关闭，同一站点并发请求时只获取一次
+	ready  chan struct{}
+	robots *Robots
+	err    error
+	// expires 由c.mu保护
+	expires time.Time
+}
+
+func NewCache(opts ...Option) *Cache {
+	options := defaultOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	c := &Cache{
+		hosts:   map[string]*entry{},
+		options: options,
+	}
+	if c.fetch == nil {
+		c.fetch = c.httpFetch
+	}
+	return c
+}
+
+// Get 返回u所在站点的robots.txt，无法获取时返回*UnavailableError
+func (c *Cache) Get(ctx context.Context, u *url.URL) (*Robots, error) {
+	key := u.Scheme + "://" + u.Host
+	c.mu.Lock()
+	e, ok := c.hosts[key]
+	if ok && (e.expires.IsZero() || time.Now().Before(e.expires)) {
+		c.mu.Unlock()
+		select {
+		case <-e.ready:
+			return e.robots, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	e = &entry{ready: make(chan struct{})}
+	c.hosts[key] = e
+	c.mu.Unlock()
+
+	r, ttl, err := c.load(ctx, key+"/robots.txt")
+	expires := time.Now().Add(ttl)
+	if err != nil {
+		err = &UnavailableError{Until: expires, Err: err}
+	}
+	e.robots, e.err = r, err
+	c.mu.Lock()
+	e.expires = expires
+	c.mu.Unlock()
+	close(e.ready)
+
+	return r, err
+}
+
+// Allowed u是否允许访问
+func (c *Cache) Allowed(ctx context.Context, u *url.URL) (bool, error) {
+	r, err := c.Get(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	return r.Allowed(c.userAgent, u.RequestURI()), nil
+}
+
+// CrawlDelay u所在站点要求的抓取间隔，robots.txt无法获取时返回0
+func (c *Cache) CrawlDelay(ctx context.Context, u *url.URL) time.Duration {
+	r, err := c.Get(ctx, u)
+	if err != nil {
+		return 0
+	}
+	return r.CrawlDelay(c.userAgent)
+}
+
+func (c *Cache) load(ctx context.Context, robotsURL string) (*Robots, time.Duration, error) {
+	fetch := fetchFromContext(ctx)
+	if fetch == nil {
+		fetch = c.fetch
+	}
+	status, body, err := fetch(ctx, robotsURL)
+	switch {
+	case err != nil:
+		return nil, c.errorTTL, err
+	case status >= 500:
+		return nil, c.errorTTL, fmt.Errorf("robots.txt status:%d", status)
+	case status >= 400:
+		return AllowAll, c.ttl, nil
+	case status >= 200 && status < 300:
+		return Parse(body), c.ttl, nil
+	default:
+		return AllowAll, c.ttl, nil
+	}
+}
+
+func (c *Cache) httpFetch(ctx context.Context, robotsURL string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	if c.userAgent != "*" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	// robots.txt最多读取500KB
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 500<<10))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,182 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Robots 解析后的robots.txt
+type Robots struct {
+	groups []*Group
+	// Sitemaps 文件中声明的站点地图
+	Sitemaps []string
+}
+
+// Group 适用于一组User-agent的规则
+type Group struct {
+	agents     []string
+	rules      []rule
+	CrawlDelay time.Duration
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+var (
+	// AllowAll 允许访问所有路径，robots.txt不存在时使用
+	AllowAll = &Robots{}
+	// DisallowAll 禁止访问所有路径
+	DisallowAll = &Robots{groups: []*Group{{agents: []string{"*"}, rules: []rule{{allow: false, pattern: "/"}}}}}
+)
+
+// Parse 解析robots.txt，无法识别的行被忽略
+func Parse(body []byte) *Robots {
+	r := &Robots{}
+	var (
+		cur *Group
+		// inAgents 为true表示正在读取连续的User-agent行
+		inAgents bool
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				cur = &Group{}
+				r.groups = append(r.groups, cur)
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if cur == nil {
+				continue
+			}
+			// 空的Disallow表示允许所有路径
+			if value == "" {
+				continue
+			}
+			cur.rules = append(cur.rules, rule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if cur == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				cur.CrawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			r.Sitemaps = append(r.Sitemaps, value)
+		default:
+			inAgents = false
+		}
+	}
+
+	return r
+}
+
+// Group 返回适用于agent的规则组，优先匹配名称最长的User-agent，其次为*
+func (r *Robots) Group(agent string) *Group {
+	agent = strings.ToLower(agent)
+	var (
+		best    *Group
+		bestLen int
+		star    *Group
+	)
+	for _, g := range r.groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if star == nil {
+					star = g
+				}
+				continue
+			}
+			if strings.Contains(agent, a) && len(a) > bestLen {
+				best, bestLen = g, len(a)
+			}
+		}
+	}
+	if best != nil {
+		return best
+	}
+	return star
+}
+
+// Allowed agent是否可以访问path，path包含查询参数
+func (r *Robots) Allowed(agent, path string) bool {
+	g := r.Group(agent)
+	if g == nil {
+		return true
+	}
+	return g.Allowed(path)
+}
+
+// CrawlDelay 适用于agent的抓取间隔
+func (r *Robots) CrawlDelay(agent string) time.Duration {
+	g := r.Group(agent)
+	if g == nil {
+		return 0
+	}
+	return g.CrawlDelay
+}
+
+// Allowed 以匹配长度最长的规则为准，长度相同时Allow优先
+func (g *Group) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed, matched := true, -1
+	for _, rl := range g.rules {
+		if !match(rl.pattern, path) {
+			continue
+		}
+		if n := len(rl.pattern); n > matched || (n == matched && rl.allow) {
+			allowed, matched = rl.allow, n
+		}
+	}
+	return allowed
+}
+
+// match 支持*匹配任意字符，$匹配路径结尾
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	if len(parts) == 1 {
+		return !anchored || len(path) == len(parts[0])
+	}
+	pos := len(parts[0])
+	middle, last := parts[1:len(parts)-1], parts[len(parts)-1]
+	for _, part := range middle {
+		i := strings.Index(path[pos:], part)
+		if i < 0 {
+			return false
+		}
+		pos += i + len(part)
+	}
+	if anchored {
+		// 最后一段需要匹配到结尾
+		return len(path)-len(last) >= pos && strings.HasSuffix(path, last)
+	}
+	return strings.Contains(path[pos:], last)
+}
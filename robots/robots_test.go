@@ -0,0 +1,125 @@
+package robots
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRobots = `
+# comment
+User-agent: *
+Disallow: /search
+Allow: /search/about
+Disallow: /*.json$
+Disallow: /tmp*/cache
+Crawl-delay: 2
+
+User-agent: crawlerbot
+User-agent: otherbot
+Disallow: /private
+Crawl-delay: 0.5
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+func TestAllowed(t *testing.T) {
+	r := Parse([]byte(testRobots))
+	tests := []struct {
+		agent string
+		path  string
+		want  bool
+	}{
+		{"*", "/", true},
+		{"*", "/search", false},
+		{"*", "/search?q=go", false},
+		{"*", "/search/about", true},
+		{"*", "/data.json", false},
+		{"*", "/data.json?x=1", true},
+		{"*", "/tmp1/cache/a", false},
+		{"*", "/tmp/other", true},
+		{"Mozilla/5.0 (compatible; CrawlerBot/1.0)", "/search", true},
+		{"crawlerbot", "/private/a", false},
+		{"otherbot", "/private", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, r.Allowed(tt.agent, tt.path), "%s %s", tt.agent, tt.path)
+	}
+	assert.Equal(t, 2*time.Second, r.CrawlDelay("*"))
+	assert.Equal(t, 500*time.Millisecond, r.CrawlDelay("crawlerbot"))
+	assert.Equal(t, []string{"https://example.com/sitemap.xml"}, r.Sitemaps)
+	assert.True(t, Parse(nil).Allowed("*", "/search"))
+}
+
+func TestCache(t *testing.T) {
+	var calls int32
+	status := map[string]int{
+		"https://a.com/robots.txt": 200,
+		"https://b.com/robots.txt": 404,
+		"https://c.com/robots.txt": 503,
+	}
+	c := NewCache(WithFetch(func(ctx context.Context, u string) (int, []byte, error) {
+		atomic.AddInt32(&calls, 1)
+		return status[u], []byte(testRobots), nil
+	}))
+	ctx := context.Background()
+	parse := func(s string) *url.URL {
+		u, _ := url.Parse(s)
+		return u
+	}
+	allowed := func(s string) bool {
+		ok, err := c.Allowed(ctx, parse(s))
+		require.NoError(t, err)
+		return ok
+	}
+	assert.False(t, allowed("https://a.com/search?q=1"))
+	assert.True(t, allowed("https://a.com/book"))
+	assert.Equal(t, 2*time.Second, c.CrawlDelay(ctx, parse("https://a.com/")))
+	assert.True(t, allowed("https://b.com/search"))
+	_, err := c.Allowed(ctx, parse("https://c.com/book"))
+	var unavailable *UnavailableError
+	require.ErrorAs(t, err, &unavailable)
+	assert.True(t, unavailable.Until.After(time.Now()))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCacheErrorTTL(t *testing.T) {
+	var calls int32
+	c := NewCache(WithErrorTTL(20*time.Millisecond), WithFetch(func(ctx context.Context, u string) (int, []byte, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return 0, nil, errors.New("connection refused")
+		}
+		return 200, []byte(testRobots), nil
+	}))
+	ctx := context.Background()
+	u, _ := url.Parse("https://a.com/book")
+	_, err := c.Allowed(ctx, u)
+	assert.Error(t, err)
+	_, err = c.Allowed(ctx, u)
+	assert.Error(t, err, "error cached until errorTTL")
+
+	time.Sleep(30 * time.Millisecond)
+	ok, err := c.Allowed(ctx, u)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCacheContextFetch(t *testing.T) {
+	c := NewCache(WithFetch(func(ctx context.Context, u string) (int, []byte, error) {
+		return 0, nil, errors.New("default fetch should not be used")
+	}))
+	ctx := NewContext(context.Background(), func(ctx context.Context, u string) (int, []byte, error) {
+		return 200, []byte(testRobots), nil
+	})
+	u, _ := url.Parse("https://a.com/search")
+	ok, err := c.Allowed(ctx, u)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}